@@ -3,136 +3,1404 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	netURL "net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
 	"unicode/utf8"
 
+	"github.com/Sniij/mircro-services-golang/model"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/joho/godotenv"
 )
 
-type NewsArticle struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	Date    string `json:"date"`
-}
+// NewsArticle is the article exchanged between the services.
+type NewsArticle = model.NewsArticle
+
+// RelatedArticle is a related-story link listed on an article page.
+type RelatedArticle = model.RelatedArticle
+
+// ConvertResult is one article of a batch response of the convert service.
+type ConvertResult = model.ConvertResult
+
 type S3Response struct {
 	Message  string `json:"message"`
 	Filename string `json:"filename"`
+	URL      string `json:"url,omitempty"`
+}
+
+var httpClient = &http.Client{
+	Timeout: 120 * time.Second,
+}
+
+// gptTokens sums the OpenAI tokens reported by convert-to-markdown in the current run
+var gptTokens atomic.Int64
+
+// validationFailures counts articles that failed markdown validation in the current run
+var validationFailures atomic.Int64
+
+// baseLogger writes one JSON object per line with step, category, index, status and
+// error fields so runs can be filtered in CloudWatch Logs Insights.
+var baseLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// logger is baseLogger with the request_id of the current invocation, set by Handler.
+var logger = baseLogger
+
+func init() {
+	// .env 파일 로드 (로컬 환경에서만 사용)
+	if _, isLambda := os.LookupEnv("LAMBDA_TASK_ROOT"); !isLambda {
+		if err := godotenv.Load(); err != nil {
+			logger.Info("no .env file found, falling back to system environment variables", "step", "init", "status", "skipped")
+		}
+	}
+	httpClient.Transport = model.NewTransport()
+}
+
+func main() {
+	//HandlerTest()
+	if os.Getenv("LOCAL_RUN") == "true" {
+		runLocal()
+		return
+	}
+	lambda.Start(Handler)
+}
+
+// runLocal runs the pipeline once outside Lambda. SIGINT/SIGTERM cancels the run's
+// context so no new articles are started and nothing is published, then waits up to
+// LOCAL_SHUTDOWN_GRACE (default 10s) for the in-flight requests to the other services
+// to finish, and aborts them once it elapses.
+// Only auto-push has a local mode: the other services answer single requests and
+// have no run to drain, so LOCAL_RUN is not read there.
+func runLocal() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	requests, abort := context.WithCancel(context.Background())
+	defer abort()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		response, err := handle(ctx, requests, events.APIGatewayProxyRequest{})
+		if err != nil {
+			logger.Error("local run failed", "step", "local_run", "status", "failed", "error", err)
+			return
+		}
+		logger.Info("local run finished", "step", "local_run", "status", response.StatusCode, "body", response.Body)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	grace := 10 * time.Second
+	if value := os.Getenv("LOCAL_SHUTDOWN_GRACE"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			grace = d
+		} else {
+			logger.Warn("invalid LOCAL_SHUTDOWN_GRACE, falling back to 10s", "step", "config", "value", value, "status", "fallback")
+		}
+	}
+	logger.Info("shutdown requested, waiting for in-flight uploads", "step", "local_run", "grace", grace.String(), "status", "stopping")
+	select {
+	case <-done:
+		logger.Info("in-flight uploads finished", "step", "local_run", "status", "ok")
+	case <-time.After(grace):
+		// 진행 중인 요청을 취소하고 Handler 가 정리를 마칠 때까지 기다림
+		abort()
+		<-done
+		logger.Warn("shutdown grace period elapsed, in-flight uploads aborted", "step", "local_run", "status", "cancelled")
+	}
+}
+
+// defaultSections maps each category to its Naver section page.
+// 정치, 경제, 사회, IT/과학, 세계
+// Politics, Economy, Society, IT/Science, World
+var defaultSections = map[string]string{
+	"politics": "https://news.naver.com/section/100",
+	"economy":  "https://news.naver.com/section/101",
+	"society":  "https://news.naver.com/section/102",
+	"it":       "https://news.naver.com/section/105",
+	"world":    "https://news.naver.com/section/104",
+}
+
+// sections returns the category to section URL map from AUTO_PUSH_SECTIONS, a JSON
+// object such as {"sports": "https://news.naver.com/section/107"}. The built-in
+// defaultSections are used when it is unset, malformed or empty.
+func sections() map[string]string {
+	value := os.Getenv("AUTO_PUSH_SECTIONS")
+	if value == "" {
+		return defaultSections
+	}
+	var configured map[string]string
+	if err := json.Unmarshal([]byte(value), &configured); err != nil || len(configured) == 0 {
+		logger.Warn("invalid AUTO_PUSH_SECTIONS, falling back to the default sections", "step", "config", "value", value, "status", "fallback")
+		return defaultSections
+	}
+	return configured
+}
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return handle(ctx, ctx, request)
+}
+
+// handle runs the pipeline. Cancelling ctx stops new articles and skips publishing;
+// requests is the context of the calls to the other services and AWS, so cancelling
+// it aborts the calls in flight.
+func handle(ctx, requests context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	urls := sections()
+
+	validationFailures.Store(0)
+	gptTokens.Store(0)
+	started := time.Now()
+	runID := newRunID()
+	correlationID := newCorrelationID()
+	logger = baseLogger.With("request_id", runID, "correlation_id", correlationID)
+	dest := newDestination(runID)
+
+	// ?mode=drain-dlq 는 새 기사 대신 DLQ 에 쌓인 기사를 재처리
+	if request.QueryStringParameters["mode"] == "drain-dlq" {
+		return drainDeadLetters(requests, dest)
+	}
+
+	// 이전 실행에서 이미 게시한 기사 URL 목록 로드
+	store, err := newWatermarkStore(ctx)
+	if err != nil {
+		logger.Error("failed to create watermark store", "step", "handler", "status", "failed", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to create watermark store: %v", err)}),
+		}, nil
+	}
+	var watermark *Watermark
+	if store != nil {
+		previous, err := store.Load(ctx)
+		if err != nil {
+			logger.Error("failed to load watermark", "step", "handler", "status", "failed", "error", err)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to load watermark: %v", err)}),
+			}, nil
+		}
+		watermark = NewWatermark(previous)
+	}
+
+	queue, err := newArticleQueue(ctx)
+	if err != nil {
+		logger.Error("failed to create article queue", "step", "handler", "status", "failed", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to create article queue: %v", err)}),
+		}, nil
+	}
+
+	dlq, err := newDeadLetterQueue(ctx, runID)
+	if err != nil {
+		logger.Error("failed to create dead letter queue", "step", "handler", "status", "failed", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to create dead letter queue: %v", err)}),
+		}, nil
+	}
+
+	run := &Run{
+		DeadLetters:   dlq,
+		Ctx:           ctx,
+		Requests:      requests,
+		Dest:          dest,
+		Queue:         queue,
+		Watermark:     watermark,
+		Budget:        newArticleBudget(),
+		GPTFailure:    &FailureReport{},
+		Names:         NewNameRegistry(),
+		Index:         newArticleIndex(),
+		Outcome:       NewRunOutcome(len(urls)),
+		Articles:      newSemaphore("ARTICLE_CONCURRENCY"),
+		CorrelationID: correlationID,
+	}
+
+	sections := newSemaphore("AUTO_PUSH_CONCURRENCY")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	successfulSections := 0
+	counts := make(map[string]int)
+	for category, url := range urls {
+		// 잘못된 URL 의 섹션은 실패로 기록하고 건너뜀
+		if parsed, err := netURL.Parse(url); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			logger.Warn("invalid section URL", "step", "handler", "category", category, "url", url, "status", "skipped")
+			run.Outcome.Record(category, fmt.Errorf("invalid section URL %q", url))
+			continue
+		}
+		sections.Acquire()
+		wg.Add(1)
+		go func(category, url string) {
+			defer wg.Done()
+			defer sections.Release()
+			converted, seen := processArticles(url, category, run)
+			mu.Lock()
+			counts[category] = converted
+			// 증분 실행에서 새 기사가 없는 조용한 섹션도 워터마크로 건너뛴 기사가 있으면 성공
+			if converted > 0 || seen > 0 {
+				successfulSections++
+			}
+			mu.Unlock()
+		}(category, url)
+	}
+	wg.Wait()
+	logger.Info("sections finished", "step", "handler", "succeeded", successfulSections, "total", len(urls), "validation_failures", validationFailures.Load())
+	run.GPTFailure.Report(requests)
+
+	_, gptFailed := run.GPTFailure.Counts()
+	WriteRunMetrics(requests, RunMetrics{
+		RunID:      runID,
+		Started:    started,
+		Duration:   time.Since(started),
+		Categories: counts,
+		Tokens:     gptTokens.Load(),
+		Failures:   gptFailed + int(validationFailures.Load()),
+	})
+
+	// 중단된 실행의 결과는 일부만 있으므로 워터마크 저장과 GitHub 게시를 하지 않음
+	if err := ctx.Err(); err != nil {
+		logger.Warn("run cancelled, skipping watermark and upload to GitHub", "step", "handler", "status", "cancelled", "error", err)
+		return run.Outcome.Response(http.StatusServiceUnavailable, "error", fmt.Sprintf("Run cancelled: %v", err)), nil
+	}
+
+	// 모든 기사가 실패한 실행은 스케줄러/알림에서 구분할 수 있도록 실패 응답
+	summary := run.Outcome.Summary()
+	if summary.Succeeded == 0 && summary.Failed > 0 {
+		logger.Error("every article failed, skipping upload to GitHub", "step", "handler", "status", "failed", "failed", summary.Failed)
+		return run.Outcome.Response(http.StatusInternalServerError, "error", "Every article failed"), nil
+	}
+
+	// 대부분의 섹션이 실패한 날은 GitHub 에 올리지 않고 재실행할 수 있도록 실패 응답
+	required := requiredSections(len(urls))
+	if successfulSections < required {
+		logger.Error("too few sections produced articles, skipping upload to GitHub", "step", "handler", "status", "failed", "succeeded", successfulSections, "total", len(urls), "required", required)
+		return run.Outcome.Response(http.StatusInternalServerError, "error", fmt.Sprintf("Only %d of %d sections produced articles (required %d)", successfulSections, len(urls), required)), nil
+	}
+
+	// GitHub 게시 전에 올려 index.json 도 같은 커밋에 포함되도록 함
+	if run.Index != nil {
+		index, err := run.Index.JSON()
+		if err == nil {
+			err = dest.WriteIndex(ctx, index)
+		}
+		if err != nil {
+			logger.Error("failed to write JSON index", "step", "handler", "status", "failed", "error", err)
+		}
+	}
+
+	// 개발 중 S3 결과만 확인할 때는 GitHub 푸시를 건너뜀
+	if os.Getenv("SKIP_GITHUB") == "true" {
+		logger.Info("SKIP_GITHUB is set, skipping upload to GitHub", "step", "handler", "status", "skipped")
+		run.Outcome.GitHubPush("skipped")
+		return run.Outcome.Response(http.StatusOK, "message", "Upload to GitHub skipped (SKIP_GITHUB=true)"), nil
+	}
+	if err := dest.Publish(requests); err != nil {
+		logger.Error("failed to upload to GitHub", "step", "upload_github", "status", "failed", "error", err)
+		run.Outcome.GitHubPush("failed")
+		return run.Outcome.Response(http.StatusBadGateway, "error", fmt.Sprintf("Failed to upload to GitHub: %v", err)), nil
+	}
+	run.Outcome.GitHubPush("pushed")
+
+	// GitHub 게시까지 끝난 기사만 다음 실행에서 건너뛰도록 게시 후에 저장
+	if store != nil {
+		if err := store.Save(ctx, watermark.URLs()); err != nil {
+			logger.Error("failed to save watermark", "step", "handler", "status", "failed", "error", err)
+		}
+	}
+
+	return run.Outcome.Response(http.StatusOK, "message", "Run completed"), nil
+}
+
+// requiredSections returns how many sections must produce articles before
+// committing to GitHub. MIN_SUCCESSFUL_SECTIONS is either a count (e.g. "3")
+// or a fraction of all sections below 1 (e.g. "0.6"). Unset means no threshold.
+func requiredSections(total int) int {
+	value := os.Getenv("MIN_SUCCESSFUL_SECTIONS")
+	if value == "" {
+		return 0
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold < 0 {
+		logger.Warn("invalid MIN_SUCCESSFUL_SECTIONS, ignoring threshold", "step", "config", "value", value, "status", "fallback")
+		return 0
+	}
+	if threshold < 1 {
+		return int(math.Ceil(threshold * float64(total)))
+	}
+	return int(threshold)
+}
+
+func HandlerTest() {
+	// 정치, 경제, 사회, IT/과학, 세계
+	// Politics, Economy, Society, IT/Science, World
+	urls := map[string]string{
+		"politics": "https://news.naver.com/section/100",
+	}
+
+	run := &Run{Dest: newDestination(newRunID()), GPTFailure: &FailureReport{}, Names: NewNameRegistry()}
+	var wg sync.WaitGroup
+	for category, url := range urls {
+		wg.Add(1)
+		go func(category, url string) {
+			defer wg.Done()
+			processArticles(url, category, run)
+		}(category, url)
+	}
+	wg.Wait()
+	if err := run.Dest.Publish(context.Background()); err != nil {
+		logger.Error("failed to upload to GitHub", "step", "upload_github", "status", "failed", "error", err)
+	}
+}
+
+// Run holds the state shared by every section of one invocation.
+type Run struct {
+	// Ctx is cancelled when the run should stop starting new articles. May be nil.
+	Ctx context.Context
+	// Requests is the context of the calls to the other services, cancelled when
+	// they must be aborted. May be nil, then Ctx is used.
+	Requests    context.Context
+	Dest        Destination
+	Watermark   *Watermark
+	Budget      *ArticleBudget
+	GPTFailure  *FailureReport
+	Names       *NameRegistry
+	Queue       *ArticleQueue
+	DeadLetters *DeadLetterQueue
+	Index       *ArticleIndex
+	Outcome     *RunOutcome
+	// Articles limits the articles processed at once across all sections.
+	Articles semaphore
+	// CorrelationID identifies the invocation in the requests to the crawling service.
+	CorrelationID string
+}
+
+// requests returns the context for the calls to the other services.
+func (r *Run) requests() context.Context {
+	switch {
+	case r.Requests != nil:
+		return r.Requests
+	case r.Ctx != nil:
+		return r.Ctx
+	}
+	return context.Background()
+}
+
+// semaphore limits how much work runs in parallel. A nil semaphore does not limit.
+type semaphore chan struct{}
+
+// newSemaphore returns a semaphore sized by the env var name, or nil (no limit,
+// the default) when it is unset or invalid.
+func newSemaphore(name string) semaphore {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Warn("invalid limit, running without a limit", "step", "config", "name", name, "value", value, "status", "fallback")
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+func (s semaphore) Acquire() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+func (s semaphore) Release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// SectionOutcome counts what happened to the articles of one category: how many were
+// scraped, converted (or failed to convert) and uploaded, and how many reached their
+// destination or failed, keeping the first error.
+type SectionOutcome struct {
+	Scraped          int    `json:"scraped"`
+	Converted        int    `json:"converted"`
+	ConversionFailed int    `json:"conversion_failed"`
+	Uploaded         int    `json:"uploaded"`
+	Succeeded        int    `json:"succeeded"`
+	Failed           int    `json:"failed"`
+	FirstError       string `json:"first_error,omitempty"`
+}
+
+// RunSummary is the JSON body of the Handler response.
+type RunSummary struct {
+	Message    string                     `json:"message,omitempty"`
+	Error      string                     `json:"error,omitempty"`
+	Categories int                        `json:"categories"`
+	Scraped    int                        `json:"scraped"`
+	Succeeded  int                        `json:"succeeded"`
+	Failed     int                        `json:"failed"`
+	GitHubPush string                     `json:"github_push"`
+	Sections   map[string]*SectionOutcome `json:"sections"`
+}
+
+// RunOutcome collects the SectionOutcome of every category in a run so the response
+// can tell a healthy run from a total failure. A nil RunOutcome records nothing.
+type RunOutcome struct {
+	mu         sync.Mutex
+	categories int
+	githubPush string
+	sections   map[string]*SectionOutcome
+}
+
+// NewRunOutcome returns a RunOutcome for a run over the given number of categories.
+func NewRunOutcome(categories int) *RunOutcome {
+	return &RunOutcome{
+		categories: categories,
+		githubPush: "not_run",
+		sections:   make(map[string]*SectionOutcome),
+	}
+}
+
+// update calls fn with the SectionOutcome of category under the lock.
+func (o *RunOutcome) update(category string, fn func(section *SectionOutcome)) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	section, ok := o.sections[category]
+	if !ok {
+		section = &SectionOutcome{}
+		o.sections[category] = section
+	}
+	fn(section)
+}
+
+// Scraped records the number of articles scraped for category.
+func (o *RunOutcome) Scraped(category string, n int) {
+	o.update(category, func(section *SectionOutcome) { section.Scraped += n })
+}
+
+// Converted records one markdown conversion of category, as failed when ok is false.
+func (o *RunOutcome) Converted(category string, ok bool) {
+	o.update(category, func(section *SectionOutcome) {
+		if ok {
+			section.Converted++
+		} else {
+			section.ConversionFailed++
+		}
+	})
+}
+
+// Uploaded records one article of category written to the destination.
+func (o *RunOutcome) Uploaded(category string) {
+	o.update(category, func(section *SectionOutcome) { section.Uploaded++ })
+}
+
+// Record counts one article of category, as a failure when err is set. A section
+// that could not be scraped is recorded as a single failure.
+func (o *RunOutcome) Record(category string, err error) {
+	o.update(category, func(section *SectionOutcome) {
+		if err == nil {
+			section.Succeeded++
+			return
+		}
+		section.Failed++
+		if section.FirstError == "" {
+			section.FirstError = err.Error()
+		}
+	})
+}
+
+// GitHubPush records whether the GitHub push ran: "pushed", "skipped" or "failed".
+func (o *RunOutcome) GitHubPush(status string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.githubPush = status
+}
+
+// Summary returns the totals and per-category outcomes of the run.
+func (o *RunOutcome) Summary() RunSummary {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	summary := RunSummary{
+		Categories: o.categories,
+		GitHubPush: o.githubPush,
+		Sections:   make(map[string]*SectionOutcome, len(o.sections)),
+	}
+	for category, section := range o.sections {
+		copied := *section
+		summary.Sections[category] = &copied
+		summary.Scraped += section.Scraped
+		summary.Succeeded += section.Succeeded
+		summary.Failed += section.Failed
+	}
+	return summary
+}
+
+// Response returns a JSON response with the run summary, with key ("message" or
+// "error") set to message.
+func (o *RunOutcome) Response(statusCode int, key, message string) events.APIGatewayProxyResponse {
+	summary := o.Summary()
+	if key == "error" {
+		summary.Error = message
+	} else {
+		summary.Message = message
+	}
+	body, err := json.Marshal(summary)
+	if err != nil {
+		body = []byte(model.JSONBody(map[string]interface{}{key: message}))
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: string(body),
+	}
+}
+
+// IndexEntry describes one uploaded article in news/<date>/index.json.
+type IndexEntry struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Category string `json:"category"`
+	Date     string `json:"date"`
+	URL      string `json:"url"`
+	Key      string `json:"key"`
+	// Icon is the CATEGORY_ICONS icon of Category, the one convert-to-markdown puts in the heading
+	Icon string `json:"icon,omitempty"`
+}
+
+// ArticleIndex collects the uploaded articles of a run for the JSON index.
+// A nil ArticleIndex collects nothing.
+type ArticleIndex struct {
+	mu      sync.Mutex
+	entries []IndexEntry
+}
+
+// newArticleIndex returns nil unless WRITE_JSON_INDEX=true.
+func newArticleIndex() *ArticleIndex {
+	if os.Getenv("WRITE_JSON_INDEX") != "true" {
+		return nil
+	}
+	return &ArticleIndex{}
+}
+
+func (x *ArticleIndex) Add(entry IndexEntry) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.entries = append(x.entries, entry)
+}
+
+// JSON returns the entries sorted by key so the index is stable across runs.
+func (x *ArticleIndex) JSON() ([]byte, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	entries := append([]IndexEntry{}, x.entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return json.MarshalIndent(entries, "", "  ")
+}
+
+// NameRegistry tracks the filenames written in a run so that two articles mapped to
+// the same name (e.g. duplicate category names) do not overwrite each other.
+// A nil NameRegistry performs no tracking.
+type NameRegistry struct {
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func NewNameRegistry() *NameRegistry {
+	return &NameRegistry{used: make(map[string]bool)}
+}
+
+// Claim reserves name for this run. When it is already taken, a numeric suffix
+// (_2, _3, ...) is appended and the collision is logged.
+func (r *NameRegistry) Claim(name string) string {
+	if r == nil {
+		return name
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	claimed := name
+	for n := 2; r.used[claimed]; n++ {
+		claimed = name + "_" + strconv.Itoa(n)
+	}
+	if claimed != name {
+		logger.Warn("filename collision, already written in this run", "step", "process", "id", name, "claimed", claimed, "status", "renamed")
+	}
+	r.used[claimed] = true
+	return claimed
+}
+
+// FailureReport aggregates GPT conversion failures across a run so they can be
+// reported as a single signal instead of scattered per-article log lines.
+type FailureReport struct {
+	mu      sync.Mutex
+	total   int
+	failed  int
+	samples []string
+}
+
+// maxFailureSamples is the number of failure messages kept for the summary.
+const maxFailureSamples = 5
+
+// Record counts one converted article, with err set when its GPT processing failed.
+func (r *FailureReport) Record(id string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total++
+	if err == nil {
+		return
+	}
+	r.failed++
+	if len(r.samples) < maxFailureSamples {
+		r.samples = append(r.samples, fmt.Sprintf("%s: %v", id, err))
+	}
+}
+
+// Counts returns the number of recorded articles and how many of them failed.
+func (r *FailureReport) Counts() (int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total, r.failed
+}
+
+// RunMetrics summarizes one auto-push run for historical dashboards.
+type RunMetrics struct {
+	RunID      string
+	Started    time.Time
+	Duration   time.Duration
+	Categories map[string]int
+	Tokens     int64
+	Failures   int
+}
+
+// WriteRunMetrics puts the run summary into the METRICS_TABLE DynamoDB table, keyed by
+// run_date (S) and run_id (S). It does nothing when METRICS_TABLE is unset, and
+// failures are only logged so they never fail the run.
+func WriteRunMetrics(ctx context.Context, metrics RunMetrics) {
+	table := os.Getenv("METRICS_TABLE")
+	if table == "" {
+		return
+	}
+
+	articles := 0
+	categories := make(map[string]dynamodbtypes.AttributeValue)
+	for category, count := range metrics.Categories {
+		articles += count
+		categories[category] = &dynamodbtypes.AttributeValueMemberN{Value: strconv.Itoa(count)}
+	}
+	item := map[string]dynamodbtypes.AttributeValue{
+		"run_date":    &dynamodbtypes.AttributeValueMemberS{Value: metrics.Started.Format("2006-01-02")},
+		"run_id":      &dynamodbtypes.AttributeValueMemberS{Value: metrics.RunID},
+		"started_at":  &dynamodbtypes.AttributeValueMemberS{Value: metrics.Started.Format(time.RFC3339)},
+		"duration_ms": &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(metrics.Duration.Milliseconds(), 10)},
+		"categories":  &dynamodbtypes.AttributeValueMemberM{Value: categories},
+		"articles":    &dynamodbtypes.AttributeValueMemberN{Value: strconv.Itoa(articles)},
+		"tokens":      &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(metrics.Tokens, 10)},
+		"failures":    &dynamodbtypes.AttributeValueMemberN{Value: strconv.Itoa(metrics.Failures)},
+	}
+
+	region, err := model.Region()
+	if err != nil {
+		logger.Error("failed to resolve region for metrics", "step", "metrics", "status", "failed", "error", err)
+		return
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		logger.Error("failed to load AWS config for metrics", "step", "metrics", "status", "failed", "error", err)
+		return
+	}
+	_, err = dynamodb.NewFromConfig(cfg).PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      item,
+	})
+	if err != nil {
+		logger.Error("failed to write run metrics", "step", "metrics", "status", "failed", "error", err)
+	}
+}
+
+// Report logs a single summary line and, when ALERT_WEBHOOK_URL is set and the
+// failure rate exceeds GPT_FAILURE_ALERT_RATE (default 0.5), posts it to the webhook.
+func (r *FailureReport) Report(ctx context.Context) {
+	r.mu.Lock()
+	total, failed := r.total, r.failed
+	samples := strings.Join(r.samples, "; ")
+	r.mu.Unlock()
+
+	if total == 0 {
+		return
+	}
+	rate := float64(failed) / float64(total)
+	summary := fmt.Sprintf("GPT failures: %d/%d articles (%.0f%%). Samples: %s", failed, total, rate*100, samples)
+	status := "ok"
+	if failed > 0 {
+		status = "failed"
+	}
+	logger.Info("GPT failure summary", "step", "report", "failed", failed, "total", total, "rate", rate, "samples", samples, "status", status)
+
+	webhookURL := os.Getenv("ALERT_WEBHOOK_URL")
+	if webhookURL == "" || failed == 0 {
+		return
+	}
+	threshold := 0.5
+	if value := os.Getenv("GPT_FAILURE_ALERT_RATE"); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			threshold = parsed
+		} else {
+			logger.Warn("invalid GPT_FAILURE_ALERT_RATE, falling back to default", "step", "config", "value", value, "default", threshold, "status", "fallback")
+		}
+	}
+	if rate > threshold {
+		if err := NotifyWebhook(ctx, webhookURL, summary); err != nil {
+			logger.Error("failed to send alert", "step", "report", "status", "failed", "error", err)
+		}
+	}
+}
+
+// NotifyWebhook posts a Slack-compatible {"text": message} payload to url.
+func NotifyWebhook(ctx context.Context, url string, message string) error {
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status code %d", res.StatusCode)
+	}
+	return nil
+}
+
+// ArticleBudget caps the number of articles processed across all sections.
+// A nil ArticleBudget means no cap.
+type ArticleBudget struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+// newArticleBudget reads MAX_ARTICLES, returning nil when it is unset or invalid.
+func newArticleBudget() *ArticleBudget {
+	value := os.Getenv("MAX_ARTICLES")
+	if value == "" {
+		return nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		logger.Warn("invalid MAX_ARTICLES, ignoring cap", "step", "config", "value", value, "status", "fallback")
+		return nil
+	}
+	return &ArticleBudget{remaining: limit}
+}
+
+// Take reserves one article, reporting false once the cap is reached.
+func (b *ArticleBudget) Take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// Exhausted reports whether no more articles may be processed.
+func (b *ArticleBudget) Exhausted() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining <= 0
+}
+
+// processArticles scrapes, converts and uploads a section, returning the number
+// of articles that were converted successfully and the number skipped because the
+// previous run already published them. With CONVERT_BATCH=true the articles
+// are sent to the convert service CONVERT_BATCH_SIZE (default 5) at a time, which
+// needs BATCH_GPT=true there; ARTICLE_CONCURRENCY then limits the batches in flight.
+func processArticles(url, category string, run *Run) (int, int) {
+	if run.Budget.Exhausted() {
+		logger.Warn("section truncated: MAX_ARTICLES reached before scraping", "step", "scrape", "category", category, "status", "skipped")
+		return 0, 0
+	}
+	logger.Info("start to process articles", "step", "scrape", "category", category)
+
+	articles, err := Scrape(run.requests(), url, run.CorrelationID)
+	if err != nil {
+		logger.Error("failed to get articles", "step", "scrape", "category", category, "status", "failed", "error", err)
+		run.Outcome.Record(category, fmt.Errorf("failed to scrape: %v", err))
+		return 0, 0
+	}
+	run.Outcome.Scraped(category, len(articles))
+
+	rules := enabledMarkdownRules()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	converted := 0
+	seen := 0
+	truncated := 0
+
+	// finish 는 변환 결과를 받은 기사 하나를 검증, 업로드하고 결과를 기록
+	finish := func(article NewsArticle, i int, correlationID string, conversion Conversion) {
+		id := articleName(category, i, article)
+		ok, err := processArticle(article, category, id, correlationID, conversion, run, rules)
+		run.Outcome.Record(category, err)
+		if err == nil {
+			run.Watermark.Add(article.URL)
+		}
+		if ok {
+			mu.Lock()
+			converted++
+			mu.Unlock()
+		}
+		if err != nil {
+			logger.Error("failed to process article", "step", "process", "category", category, "index", i, "id", id, "article_correlation_id", correlationID, "status", "failed", "error", err)
+			run.DeadLetters.Put(id, category, article, err)
+		}
+	}
+
+	batch := os.Getenv("CONVERT_BATCH") == "true"
+	var pending []NewsArticle
+	var indices []int
+	for i, article := range articles {
+		article := article
+		article.Category = category
+		if run.Watermark.Check(article.URL) {
+			// 이전 실행에서 게시된 기사이므로 다음 실행에서도 건너뛰도록 유지
+			run.Watermark.Add(article.URL)
+			seen++
+			logger.Info("skipping article already published in the last run", "step", "scrape", "category", category, "index", i, "url", article.URL, "status", "skipped")
+			continue
+		}
+		if run.Ctx != nil && run.Ctx.Err() != nil {
+			logger.Warn("section stopped: run cancelled", "step", "scrape", "category", category, "status", "cancelled")
+			break
+		}
+		if !run.Budget.Take() {
+			truncated++
+			continue
+		}
+		if batch {
+			pending = append(pending, article)
+			indices = append(indices, i)
+			continue
+		}
+		run.Articles.Acquire()
+		wg.Add(1)
+		go func(article NewsArticle, i int) {
+			defer wg.Done()
+			defer run.Articles.Release()
+			correlationID := newCorrelationID()
+			finish(article, i, correlationID, convertOne(run.requests(), article, correlationID))
+		}(article, i)
+	}
+
+	size := convertBatchSize()
+	for start := 0; start < len(pending); start += size {
+		end := min(start+size, len(pending))
+		run.Articles.Acquire()
+		wg.Add(1)
+		go func(articles []NewsArticle, indices []int) {
+			defer wg.Done()
+			defer run.Articles.Release()
+			correlationID := newCorrelationID()
+			conversions := ConvertBatch(run.requests(), articles, correlationID)
+			for k, article := range articles {
+				finish(article, indices[k], correlationID, conversions[k])
+			}
+		}(pending[start:end], indices[start:end])
+	}
+
+	if truncated > 0 {
+		logger.Warn("section truncated by MAX_ARTICLES", "step", "scrape", "category", category, "skipped", truncated)
+	}
+
+	wg.Wait()
+	return converted, seen
+}
+
+// convertBatchSize reads CONVERT_BATCH_SIZE, the number of articles per batch request
+// to the convert service (default 5).
+func convertBatchSize() int {
+	value := os.Getenv("CONVERT_BATCH_SIZE")
+	if value == "" {
+		return 5
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Warn("invalid CONVERT_BATCH_SIZE, falling back to 5", "step", "config", "value", value, "status", "fallback")
+		return 5
+	}
+	return n
+}
+
+// QueueMessage is the JSON body published for each converted article.
+type QueueMessage struct {
+	Name     string      `json:"name"`
+	Category string      `json:"category"`
+	Article  NewsArticle `json:"article"`
+	Markdown string      `json:"markdown"`
+}
+
+// ArticleQueue publishes converted articles to an SQS queue (ARTICLE_QUEUE_URL) or an
+// SNS topic (ARTICLE_TOPIC_ARN). With ARTICLE_QUEUE_MODE=only the queue replaces the
+// S3 upload; by default it is used in addition to it.
+type ArticleQueue struct {
+	SQS      *sqs.Client
+	SNS      *sns.Client
+	QueueURL string
+	TopicARN string
+	Only     bool
+}
+
+// newArticleQueue returns nil when neither ARTICLE_QUEUE_URL nor ARTICLE_TOPIC_ARN is set.
+func newArticleQueue(ctx context.Context) (*ArticleQueue, error) {
+	queueURL := os.Getenv("ARTICLE_QUEUE_URL")
+	topicARN := os.Getenv("ARTICLE_TOPIC_ARN")
+	if queueURL == "" && topicARN == "" {
+		return nil, nil
+	}
+
+	region, err := model.Region()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	queue := &ArticleQueue{
+		QueueURL: queueURL,
+		TopicARN: topicARN,
+		Only:     os.Getenv("ARTICLE_QUEUE_MODE") == "only",
+	}
+	if queueURL != "" {
+		queue.SQS = sqs.NewFromConfig(cfg)
+	}
+	if topicARN != "" {
+		queue.SNS = sns.NewFromConfig(cfg)
+	}
+	return queue, nil
+}
+
+// Publish sends message to every configured target.
+func (q *ArticleQueue) Publish(ctx context.Context, message QueueMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if q.SQS != nil {
+		_, err := q.SQS.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:    aws.String(q.QueueURL),
+			MessageBody: aws.String(string(body)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send SQS message: %v", err)
+		}
+	}
+	if q.SNS != nil {
+		_, err := q.SNS.Publish(ctx, &sns.PublishInput{
+			TopicArn: aws.String(q.TopicARN),
+			Message:  aws.String(string(body)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to publish SNS message: %v", err)
+		}
+	}
+	return nil
+}
+
+// processArticle validates and uploads one article given its conversion. It reports
+// whether the article was converted, and returns an error when it did not reach its
+// destination. correlationID is sent to the upload service for this article.
+func processArticle(article NewsArticle, category, id, correlationID string, conversion Conversion, run *Run, rules []markdownRule) (bool, error) {
+	markdown, err := conversion.Markdown, conversion.Err
+	run.Outcome.Converted(category, err == nil)
+	if err != nil {
+		run.GPTFailure.Record(id, err)
+		return false, fmt.Errorf("failed to convert article to markdown: %v", err)
+	}
+	if failedStages := conversion.FailedStages; len(failedStages) > 0 {
+		run.GPTFailure.Record(id, fmt.Errorf("GPT stages failed: %s", strings.Join(failedStages, ",")))
+	} else {
+		run.GPTFailure.Record(id, nil)
+	}
+	if err := ValidateMarkdown(cleanANSI(string(markdown)), rules); err != nil {
+		validationFailures.Add(1)
+		if os.Getenv("MARKDOWN_VALIDATION_MODE") != "flag" {
+			return false, fmt.Errorf("markdown validation failed: %v", err)
+		}
+		logger.Warn("markdown validation flagged", "step", "convert", "category", category, "id", id, "article_correlation_id", correlationID, "status", "flagged", "error", err)
+	}
+	logger.Info("converted to markdown", "step", "convert", "category", category, "id", id, "article_correlation_id", correlationID, "status", "ok")
+	name := run.Names.Claim(id)
+	if run.Queue != nil {
+		err := run.Queue.Publish(run.requests(), QueueMessage{Name: name, Category: category, Article: article, Markdown: string(markdown)})
+		if err != nil {
+			// 큐만 사용하는 모드에서는 게시 실패가 곧 기사 유실
+			if run.Queue.Only {
+				return false, fmt.Errorf("failed to publish to queue: %v", err)
+			}
+			logger.Error("failed to publish to queue", "step", "queue", "category", category, "id", name, "article_correlation_id", correlationID, "status", "failed", "error", err)
+		}
+		if run.Queue.Only {
+			return true, nil
+		}
+	}
+
+	key, err := run.Dest.Upload(run.requests(), markdown, name, article.URL, correlationID)
+	if err != nil {
+		return true, fmt.Errorf("failed to upload: %v", err)
+	}
+	run.Outcome.Uploaded(category)
+	run.Index.Add(IndexEntry{
+		ID:       article.ID,
+		Title:    article.Title,
+		Category: category,
+		Date:     article.Date,
+		URL:      article.URL,
+		Key:      key,
+		Icon:     model.CategoryIcon(category),
+	})
+	logger.Info("uploaded", "step", "upload_s3", "category", category, "id", name, "article_correlation_id", correlationID, "status", "ok")
+	return true, nil
+}
+
+// DeadLetter is an article that failed to be converted or uploaded, stored as JSON
+// so a later drain run can retry it.
+type DeadLetter struct {
+	Name     string      `json:"name"`
+	Category string      `json:"category"`
+	Error    string      `json:"error"`
+	Article  NewsArticle `json:"article"`
+}
+
+// DeadLetterQueue stores failed articles under dlq/<date>/ in DLQ_BUCKET.
+// A nil DeadLetterQueue drops them.
+type DeadLetterQueue struct {
+	Client     *s3.Client
+	BucketName string
+	// RunID keeps the dead letters of each run apart, see deadLetterKey.
+	RunID string
+}
+
+// newDeadLetterQueue returns nil unless ENABLE_DLQ=true. runID is the run whose
+// failed articles Put stores.
+func newDeadLetterQueue(ctx context.Context, runID string) (*DeadLetterQueue, error) {
+	if os.Getenv("ENABLE_DLQ") != "true" {
+		return nil, nil
+	}
+	bucketName := os.Getenv("DLQ_BUCKET")
+	if bucketName == "" {
+		return nil, fmt.Errorf("ENABLE_DLQ is set but DLQ_BUCKET is empty")
+	}
+
+	region, err := model.Region("S3_REGION")
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	return &DeadLetterQueue{
+		Client:     s3.NewFromConfig(cfg),
+		BucketName: bucketName,
+		RunID:      runID,
+	}, nil
 }
 
-var httpClient = &http.Client{
-	Timeout: 120 * time.Second,
+// deadLetterKey returns dlq/<date>/<run ID>/<name>.json, so a second run on the same
+// day (a re-run, backfill or retry) does not overwrite the dead letters of the first.
+func deadLetterKey(day time.Time, runID, name string) string {
+	return fmt.Sprintf("dlq/%s/%s/%s.json", day.Format("2006-01-02"), runID, name)
 }
 
-func init() {
-	// .env 파일 로드 (로컬 환경에서만 사용)
-	if _, isLambda := os.LookupEnv("LAMBDA_TASK_ROOT"); !isLambda {
-		if err := godotenv.Load(); err != nil {
-			log.Println("No .env file found. Falling back to system environment variables.")
+// Put stores the raw article. Failures are only logged so they never block the run.
+func (q *DeadLetterQueue) Put(name, category string, article NewsArticle, cause error) {
+	if q == nil {
+		return
+	}
+	body, err := json.Marshal(DeadLetter{Name: name, Category: category, Error: cause.Error(), Article: article})
+	if err != nil {
+		logger.Error("failed to encode dead letter", "step", "dlq", "category", category, "id", name, "status", "failed", "error", err)
+		return
+	}
+
+	// 취소된 실행의 실패 기사도 남도록 실행 컨텍스트와 별개로 저장
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key := deadLetterKey(time.Now(), q.RunID, name)
+	_, err = q.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(q.BucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		logger.Error("failed to put dead letter", "step", "dlq", "category", category, "id", name, "key", key, "status", "failed", "error", err)
+		return
+	}
+	logger.Info("stored failed article", "step", "dlq", "category", category, "id", name, "key", key, "status", "ok")
+}
+
+// Keys lists every stored dead letter.
+func (q *DeadLetterQueue) Keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(q.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(q.BucketName),
+		Prefix: aws.String("dlq/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list dead letters: %v", err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
 		}
 	}
+	return keys, nil
 }
 
-func main() {
-	//HandlerTest()
-	lambda.Start(Handler)
+// Get reads the dead letter stored at key.
+func (q *DeadLetterQueue) Get(ctx context.Context, key string) (DeadLetter, error) {
+	var letter DeadLetter
+	output, err := q.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(q.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return letter, fmt.Errorf("failed to get dead letter: %v", err)
+	}
+	defer output.Body.Close()
+
+	if err := json.NewDecoder(output.Body).Decode(&letter); err != nil {
+		return letter, fmt.Errorf("failed to decode dead letter: %v", err)
+	}
+	return letter, nil
 }
 
-func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// 정치, 경제, 사회, IT/과학, 세계
-	// Politics, Economy, Society, IT/Science, World
-	urls := map[string]string{
-		"politics": "https://news.naver.com/section/100",
-		"economy":  "https://news.naver.com/section/101",
-		"society":  "https://news.naver.com/section/102",
-		"it":       "https://news.naver.com/section/105",
-		"world":    "https://news.naver.com/section/104",
+// Delete removes the dead letter stored at key.
+func (q *DeadLetterQueue) Delete(ctx context.Context, key string) error {
+	_, err := q.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(q.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter: %v", err)
 	}
+	return nil
+}
 
-	var wg sync.WaitGroup
-	for category, url := range urls {
-		wg.Add(1)
-		go func(category, url string) {
-			defer wg.Done()
-			processArticles(url, category)
-		}(category, url)
+// drainDeadLetters retries every stored dead letter, deleting the ones that reach
+// the destination, and publishes when at least one succeeded. Drained files are
+// named retry_<name> so they do not overwrite articles of a regular run.
+func drainDeadLetters(ctx context.Context, dest Destination) (events.APIGatewayProxyResponse, error) {
+	dlq, err := newDeadLetterQueue(ctx, "")
+	if err != nil || dlq == nil {
+		if err == nil {
+			err = fmt.Errorf("ENABLE_DLQ is not set")
+		}
+		logger.Error("failed to create dead letter queue", "step", "dlq", "status", "failed", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to create dead letter queue: %v", err)}),
+		}, nil
 	}
-	wg.Wait()
-	UploadToGitHub()
 
+	keys, err := dlq.Keys(ctx)
+	if err != nil {
+		logger.Error("failed to list dead letters", "step", "dlq", "status", "failed", "error", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to list dead letters: %v", err)}),
+		}, nil
+	}
+
+	// 재처리 실패 시 원래 항목이 남아 있으므로 DLQ 에 다시 쓰지 않음
+	run := &Run{Ctx: ctx, Dest: dest, GPTFailure: &FailureReport{}, Names: NewNameRegistry()}
+	rules := enabledMarkdownRules()
+	drained := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		letter, err := dlq.Get(ctx, key)
+		if err != nil {
+			logger.Warn("skipping dead letter", "step", "dlq", "key", key, "status", "skipped", "error", err)
+			continue
+		}
+		correlationID := newCorrelationID()
+		conversion := convertOne(ctx, letter.Article, correlationID)
+		if _, err := processArticle(letter.Article, letter.Category, "retry_"+letter.Name, correlationID, conversion, run, rules); err != nil {
+			logger.Error("retry of dead letter failed", "step", "dlq", "key", key, "status", "failed", "error", err)
+			continue
+		}
+		if err := dlq.Delete(ctx, key); err != nil {
+			logger.Error("failed to delete dead letter", "step", "dlq", "key", key, "status", "failed", "error", err)
+		}
+		drained++
+	}
+	logger.Info("dead letters drained", "step", "dlq", "drained", drained, "total", len(keys), "status", "ok")
+	run.GPTFailure.Report(ctx)
+
+	if drained > 0 && os.Getenv("SKIP_GITHUB") != "true" {
+		if err := dest.Publish(ctx); err != nil {
+			logger.Error("failed to upload to GitHub", "step", "upload_github", "status", "failed", "error", err)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadGateway,
+				Body: model.JSONBody(map[string]interface{}{
+					"error":   fmt.Sprintf("Failed to upload to GitHub: %v", err),
+					"drained": drained,
+					"total":   len(keys),
+				}),
+			}, nil
+		}
+	}
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
+		Body: model.JSONBody(map[string]interface{}{
+			"message": "Dead letters drained",
+			"drained": drained,
+			"total":   len(keys),
+		}),
 	}, nil
 }
 
-func HandlerTest() {
-	// 정치, 경제, 사회, IT/과학, 세계
-	// Politics, Economy, Society, IT/Science, World
-	urls := map[string]string{
-		"politics": "https://news.naver.com/section/100",
+// httpAttempts reads HTTP_RETRY_ATTEMPTS, the number of tries for each call to the
+// other services (default 3).
+func httpAttempts() int {
+	value := os.Getenv("HTTP_RETRY_ATTEMPTS")
+	if value == "" {
+		return 3
 	}
-
-	var wg sync.WaitGroup
-	for category, url := range urls {
-		wg.Add(1)
-		go func(category, url string) {
-			defer wg.Done()
-			processArticles(url, category)
-		}(category, url)
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Warn("invalid HTTP_RETRY_ATTEMPTS, falling back to 3", "step", "config", "value", value, "status", "fallback")
+		return 3
 	}
-	wg.Wait()
-	UploadToGitHub()
-
+	return n
 }
 
-func processArticles(url, category string) {
-	log.Printf("Start to process articles %s \n", category)
-
-	articles, err := Scrape(url)
-	if err != nil {
-		log.Printf("Failed to get articles for %s: %v", category, err)
-		return
+// doWithRetry sends req with httpClient up to attempts times, retrying network errors
+// and 5xx responses with exponential backoff starting at 500ms. The request body is
+// buffered so it can be replayed. The last response or error is returned.
+func doWithRetry(req *http.Request, attempts int) (*http.Response, error) {
+	if req.Body != nil && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to buffer request body: %v", err)
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.Body, _ = req.GetBody()
 	}
 
-	var wg sync.WaitGroup
-	for i, article := range articles {
-		article := article
-		wg.Add(1)
-		go func(article NewsArticle, category string, i int) {
-			defer wg.Done()
-			markdown, err := ConvertToMarkdown(article)
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		res, err := httpClient.Do(req)
+		if attempt >= attempts || (err == nil && res.StatusCode < 500) {
+			return res, err
+		}
+		if err != nil {
+			logger.Warn("request failed, retrying", "step", "retry", "host", req.URL.Host, "attempt", attempt, "attempts", attempts, "status", "failed", "error", err)
+		} else {
+			logger.Warn("request returned server error, retrying", "step", "retry", "host", req.URL.Host, "attempt", attempt, "attempts", attempts, "status", res.StatusCode)
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		// 재시도마다 본문을 처음부터 다시 보냄
+		if req.GetBody != nil {
+			body, err := req.GetBody()
 			if err != nil {
-				log.Printf("Failed to convert article to markdown for %s: %v", category, err)
-				return
+				return nil, fmt.Errorf("failed to replay request body: %v", err)
 			}
-			log.Printf("Successfully to Convert To Markdown: %s \n", category)
-
-			UploadToS3(markdown, category, i)
-			log.Printf("Successfully to Upload To S3: %s \n", category)
-		}(article, category, i)
+			req.Body = body
+		}
 	}
+}
 
-	wg.Wait()
+// setCorrelationID sets the X-Correlation-Id header read by the downstream services,
+// unless id is empty.
+func setCorrelationID(req *http.Request, id string) {
+	if id != "" {
+		req.Header.Set("X-Correlation-Id", id)
+	}
 }
-func Scrape(url string) ([]NewsArticle, error) {
+
+// Scrape asks the crawling service for the articles of the section at url.
+func Scrape(ctx context.Context, url, correlationID string) ([]NewsArticle, error) {
 	serverURL, err := netURL.QueryUnescape(os.Getenv("CRAWLING_SERVER"))
 	if err != nil {
 		return []NewsArticle{}, fmt.Errorf("failed to get server url: %v", err)
 	}
 
 	// HTTP 요청 생성
-	req, err := http.NewRequest("GET", serverURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", serverURL, nil)
 	if err != nil {
 		return []NewsArticle{}, fmt.Errorf("failed to create HTTP request: %v", err)
 	}
@@ -141,9 +1409,11 @@ func Scrape(url string) ([]NewsArticle, error) {
 	q := req.URL.Query()
 	q.Add("url", url)
 	req.URL.RawQuery = q.Encode()
+	setCorrelationID(req, correlationID)
+	model.SignRequest(req, os.Getenv("SIGNATURE_SECRET"), []byte(url))
 
 	// 요청 실행
-	res, err := httpClient.Do(req)
+	res, err := doWithRetry(req, httpAttempts())
 	if err != nil {
 		return []NewsArticle{}, fmt.Errorf("failed to send HTTP request: %v", err)
 	}
@@ -169,127 +1439,568 @@ func Scrape(url string) ([]NewsArticle, error) {
 	return articles, nil
 }
 
-func ConvertToMarkdown(article NewsArticle) ([]byte, error) {
+// Conversion is the convert service's answer for one article: the markdown and the
+// GPT stages reported as failed, or the error the article failed with.
+type Conversion struct {
+	Markdown     []byte
+	FailedStages []string
+	Err          error
+}
+
+// convertOne converts a single article with ConvertToMarkdown.
+func convertOne(ctx context.Context, article NewsArticle, correlationID string) Conversion {
+	markdown, failedStages, err := ConvertToMarkdown(ctx, article, correlationID)
+	return Conversion{Markdown: markdown, FailedStages: failedStages, Err: err}
+}
+
+// ConvertBatch sends the articles to the convert service in one request, which must
+// run with BATCH_GPT=true. It returns a Conversion for each article; when the request
+// itself fails every article gets its error.
+func ConvertBatch(ctx context.Context, articles []NewsArticle, correlationID string) []Conversion {
+	conversions := make([]Conversion, len(articles))
+	results, err := convertBatch(ctx, articles, correlationID)
+	if err == nil && len(results) != len(articles) {
+		err = fmt.Errorf("ConvertToMarkdown server returned %d results for %d articles", len(results), len(articles))
+	}
+	for i := range conversions {
+		switch {
+		case err != nil:
+			conversions[i].Err = err
+		case results[i].Status != http.StatusOK:
+			conversions[i].Err = fmt.Errorf("ConvertToMarkdown server returned status code %d: %s", results[i].Status, results[i].Error)
+		default:
+			conversions[i] = Conversion{Markdown: []byte(results[i].Document), FailedStages: results[i].Failures}
+		}
+	}
+	return conversions
+}
+
+// convertBatch posts the articles as a JSON array and decodes the ConvertResult array.
+func convertBatch(ctx context.Context, articles []NewsArticle, correlationID string) ([]ConvertResult, error) {
+	serverURL, err := netURL.QueryUnescape(os.Getenv("CONVERT_SERVER"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server url: %v", err)
+	}
+	reqBody, err := json.Marshal(articles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal articles: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", serverURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setCorrelationID(req, correlationID)
+	model.SignRequest(req, os.Getenv("SIGNATURE_SECRET"), reqBody)
+
+	res, err := doWithRetry(req, httpAttempts())
+	if err != nil {
+		return nil, fmt.Errorf("failed to send HTTP request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ConvertToMarkdown server returned status code %d", res.StatusCode)
+	}
+	var results []ConvertResult
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("failed to decode batch response: %v", err)
+	}
+	if tokens, err := strconv.ParseInt(res.Header.Get("X-GPT-Tokens"), 10, 64); err == nil {
+		gptTokens.Add(tokens)
+	}
+	return results, nil
+}
+
+// ConvertToMarkdown sends the article to the convert service. Besides the markdown it
+// returns the GPT stages the service reported as failed (kept with their original value).
+func ConvertToMarkdown(ctx context.Context, article NewsArticle, correlationID string) ([]byte, []string, error) {
 
 	serverURL, err := netURL.QueryUnescape(os.Getenv("CONVERT_SERVER"))
 	if err != nil {
-		return []byte{}, fmt.Errorf("failed to get server url: %v", err)
+		return []byte{}, nil, fmt.Errorf("failed to get server url: %v", err)
 	}
 	// HTTP 요청 객체 생성
 	reqBody, err := json.Marshal(article)
 	if err != nil {
-		return []byte{}, fmt.Errorf("failed to article request: %v", err)
+		return []byte{}, nil, fmt.Errorf("failed to article request: %v", err)
 	}
 
 	// HTTP 요청 생성
-	req, err := http.NewRequest("POST", serverURL, bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", serverURL, bytes.NewBuffer(reqBody))
 	if err != nil {
-		return []byte{}, fmt.Errorf("failed to create HTTP request: %v", err)
+		return []byte{}, nil, fmt.Errorf("failed to create HTTP request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	setCorrelationID(req, correlationID)
+	model.SignRequest(req, os.Getenv("SIGNATURE_SECRET"), reqBody)
 
 	// 요청 실행
-	res, err := httpClient.Do(req)
+	res, err := doWithRetry(req, httpAttempts())
 	if err != nil {
-		return []byte{}, fmt.Errorf("failed to send HTTP request: %v", err)
+		return []byte{}, nil, fmt.Errorf("failed to send HTTP request: %v", err)
 	}
 	defer res.Body.Close()
 
 	// HTTP 응답 상태 코드 확인
 	if res.StatusCode != http.StatusOK {
-		return []byte{}, fmt.Errorf("ConvertToMarkdown server returned status code %d", res.StatusCode)
+		return []byte{}, nil, fmt.Errorf("ConvertToMarkdown server returned status code %d", res.StatusCode)
 	}
 
 	// 응답 본문 읽기
 	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return []byte{}, fmt.Errorf("failed to read response body: %v", err)
+		return []byte{}, nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if tokens, err := strconv.ParseInt(res.Header.Get("X-GPT-Tokens"), 10, 64); err == nil {
+		gptTokens.Add(tokens)
+	}
+	var failedStages []string
+	if header := res.Header.Get("X-GPT-Failures"); header != "" {
+		failedStages = strings.Split(header, ",")
 	}
-	return resBody, nil
+	return resBody, failedStages, nil
 }
 func cleanANSI(input string) string {
 	// ANSI 이스케이프 코드 정규식
 	ansiRegex := regexp.MustCompile(`\x1B\[[0-9;]*[a-zA-Z]`)
 	return ansiRegex.ReplaceAllString(input, "")
 }
-func UploadToS3(markdown []byte, category string, i int) {
+
+// markdownRule is a single check applied to converted markdown before upload.
+type markdownRule struct {
+	Name  string
+	Check func(markdown string) error
+}
+
+var markdownRules = []markdownRule{
+	{Name: "utf8", Check: checkUTF8},
+	{Name: "frontmatter", Check: checkFrontMatter},
+	{Name: "body", Check: checkBody},
+	{Name: "control", Check: checkControlChars},
+}
+
+// enabledMarkdownRules returns the rules listed in MARKDOWN_VALIDATION
+// (comma-separated rule names, or "all"). Unset disables validation.
+func enabledMarkdownRules() []markdownRule {
+	value := os.Getenv("MARKDOWN_VALIDATION")
+	if value == "" {
+		return nil
+	}
+	if value == "all" {
+		return markdownRules
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		enabled[strings.TrimSpace(name)] = true
+	}
+	var rules []markdownRule
+	for _, rule := range markdownRules {
+		if enabled[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ValidateMarkdown returns the first rule violation found in markdown.
+func ValidateMarkdown(markdown string, rules []markdownRule) error {
+	for _, rule := range rules {
+		if err := rule.Check(markdown); err != nil {
+			return fmt.Errorf("%s: %v", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// splitFrontMatter separates a leading "---" delimited front matter block from the body.
+func splitFrontMatter(markdown string) (string, string, error) {
+	if !strings.HasPrefix(markdown, "---\n") {
+		return "", markdown, nil
+	}
+	rest := markdown[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return "", "", fmt.Errorf("front matter is not closed")
+	}
+	body := strings.TrimPrefix(rest[end+len("\n---"):], "\n")
+	return rest[:end], body, nil
+}
+
+func checkUTF8(markdown string) error {
+	if !utf8.ValidString(markdown) {
+		return fmt.Errorf("content is not valid UTF-8")
+	}
+	return nil
+}
+
+func checkFrontMatter(markdown string) error {
+	_, _, err := splitFrontMatter(markdown)
+	return err
+}
+
+func checkBody(markdown string) error {
+	_, body, err := splitFrontMatter(markdown)
+	if err != nil {
+		body = markdown
+	}
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("body is empty")
+	}
+	return nil
+}
+
+func checkControlChars(markdown string) error {
+	for i, r := range markdown {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return fmt.Errorf("control character %U at byte %d", r, i)
+		}
+	}
+	return nil
+}
+
+// articleName is the filename stem of an article, <category>_<index>, or
+// <category>_<id> when FILENAME_BY_ID=true and the crawler provided an ID.
+// The ID is stable across re-runs and reordering of the headline list.
+func articleName(category string, i int, article NewsArticle) string {
+	if os.Getenv("FILENAME_BY_ID") == "true" && article.ID != "" {
+		return category + "_" + article.ID
+	}
+	return category + "_" + strconv.Itoa(i)
+}
+
+// articleKey returns the key of an uploaded article, news/<date>/<name>.md,
+// the layout shared by upload-to-s3 and LocalDestination.
+func articleKey(name string) string {
+	today := time.Now().Format("2006-01-02")
+	return fmt.Sprintf("news/%s/%s.md", today, name)
+}
+
+// indexKey returns the key of today's JSON index.
+func indexKey() string {
+	return fmt.Sprintf("news/%s/index.json", time.Now().Format("2006-01-02"))
+}
+
+// Destination receives the converted markdown of a run and publishes it. Upload
+// takes the article URL as its source and returns the key the markdown was written to.
+type Destination interface {
+	Upload(ctx context.Context, markdown []byte, name, source, correlationID string) (string, error)
+	WriteIndex(ctx context.Context, index []byte) error
+	Publish(ctx context.Context) error
+}
+
+// newRunID returns an identifier unique to one invocation, used by upload-to-s3
+// to tell a key collision within a run apart from a re-run overwriting yesterday's key.
+func newRunID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// newCorrelationID returns a random UUID (version 4) sent as the X-Correlation-Id
+// header so an invocation or an article can be traced through the downstream services.
+func newCorrelationID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return newRunID()
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// newDestination selects the destination from UPLOAD_DESTINATION ("s3" by default, or "local").
+func newDestination(runID string) Destination {
+	if os.Getenv("UPLOAD_DESTINATION") == "local" {
+		dir := os.Getenv("LOCAL_OUTPUT_DIR")
+		if dir == "" {
+			dir = "output"
+		}
+		return LocalDestination{Dir: dir}
+	}
+	return S3Destination{RunID: runID}
+}
+
+// S3Destination uploads through the upload-to-s3 service and pushes to GitHub.
+type S3Destination struct {
+	RunID string
+}
+
+func (d S3Destination) Upload(ctx context.Context, markdown []byte, name, source, correlationID string) (string, error) {
+	return UploadToS3(ctx, markdown, name, source, d.RunID, correlationID)
+}
+
+// WriteIndex puts the index into S3_BUCKET_NAME, the bucket upload-to-s3 writes to.
+func (S3Destination) WriteIndex(ctx context.Context, index []byte) error {
+	bucketName := os.Getenv("S3_BUCKET_NAME")
+	if bucketName == "" {
+		return fmt.Errorf("S3_BUCKET_NAME is not set")
+	}
+	region, err := model.Region("S3_REGION")
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	_, err = s3.NewFromConfig(cfg).PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(indexKey()),
+		Body:        bytes.NewReader(index),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put index: %v", err)
+	}
+	logger.Info("index written", "step", "index", "filename", indexKey(), "status", "ok")
+	return nil
+}
+
+func (S3Destination) Publish(ctx context.Context) error {
+	return UploadToGitHub(ctx)
+}
+
+// LocalDestination writes markdown under Dir mirroring the S3 key layout,
+// so the pipeline can run without AWS credentials.
+type LocalDestination struct {
+	Dir string
+}
+
+func (d LocalDestination) Upload(ctx context.Context, markdown []byte, name, source, correlationID string) (string, error) {
+	key := articleKey(name)
+	return key, d.write(key, []byte(cleanANSI(string(markdown))))
+}
+
+func (d LocalDestination) WriteIndex(ctx context.Context, index []byte) error {
+	return d.write(indexKey(), index)
+}
+
+func (d LocalDestination) write(key string, content []byte) error {
+	path := filepath.Join(d.Dir, filepath.FromSlash(key))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	logger.Info("file written", "step", "upload_local", "filename", path, "status", "ok")
+	return nil
+}
+
+func (d LocalDestination) Publish(ctx context.Context) error {
+	logger.Info("local destination, skipping upload to GitHub", "step", "upload_github", "dir", d.Dir, "status", "skipped")
+	return nil
+}
+
+// Watermark is the set of article URLs published by the previous run, plus the
+// URLs published by the current one. A nil Watermark disables the check.
+type Watermark struct {
+	mu       sync.Mutex
+	previous map[string]bool
+	current  map[string]bool
+}
+
+func NewWatermark(previous []string) *Watermark {
+	w := &Watermark{
+		previous: make(map[string]bool),
+		current:  make(map[string]bool),
+	}
+	for _, url := range previous {
+		w.previous[url] = true
+	}
+	return w
+}
+
+// Check reports whether the previous run already published url.
+func (w *Watermark) Check(url string) bool {
+	if w == nil || url == "" {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.previous[url]
+}
+
+// Add records url as published in this run. Call it only once the article reached
+// its destination, so failed or skipped articles are retried by the next run.
+func (w *Watermark) Add(url string) {
+	if w == nil || url == "" {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.current[url] = true
+}
+
+// URLs returns the URLs published in this run, to be stored for the next one.
+func (w *Watermark) URLs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	urls := make([]string, 0, len(w.current))
+	for url := range w.current {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// WatermarkStore persists the watermark as a JSON array of URLs in S3.
+type WatermarkStore struct {
+	Client     *s3.Client
+	BucketName string
+	Key        string
+}
+
+// newWatermarkStore returns nil when WATERMARK_BUCKET is unset.
+func newWatermarkStore(ctx context.Context) (*WatermarkStore, error) {
+	bucketName := os.Getenv("WATERMARK_BUCKET")
+	if bucketName == "" {
+		return nil, nil
+	}
+	key := os.Getenv("WATERMARK_KEY")
+	if key == "" {
+		key = "watermark/seen-urls.json"
+	}
+
+	region, err := model.Region("S3_REGION")
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	return &WatermarkStore{
+		Client:     s3.NewFromConfig(cfg),
+		BucketName: bucketName,
+		Key:        key,
+	}, nil
+}
+
+// Load reads the stored URLs. On the first run no watermark exists yet and it returns an empty list.
+func (s *WatermarkStore) Load(ctx context.Context) ([]string, error) {
+	output, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(s.Key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			logger.Info("no watermark found, treating as first run", "step", "watermark", "key", s.Key, "status", "skipped")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get watermark: %v", err)
+	}
+	defer output.Body.Close()
+
+	var urls []string
+	if err := json.NewDecoder(output.Body).Decode(&urls); err != nil {
+		return nil, fmt.Errorf("failed to decode watermark: %v", err)
+	}
+	return urls, nil
+}
+
+// Save overwrites the stored URLs.
+func (s *WatermarkStore) Save(ctx context.Context, urls []string) error {
+	body, err := json.Marshal(urls)
+	if err != nil {
+		return fmt.Errorf("failed to encode watermark: %v", err)
+	}
+	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.BucketName),
+		Key:         aws.String(s.Key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put watermark: %v", err)
+	}
+	return nil
+}
+
+// UploadToS3 sends the markdown to the upload-to-s3 service and returns the key it
+// was written to, as reported in the filename of the response. source, the article
+// URL, is sent in x-source-sniij and stored in the object metadata.
+func UploadToS3(ctx context.Context, markdown []byte, name string, source string, runID string, correlationID string) (string, error) {
 	if !utf8.Valid(markdown) {
-		log.Printf("Input data is not valid UTF-8. Converting...")
+		logger.Warn("input data is not valid UTF-8, converting", "step", "upload_s3", "id", name)
 		markdown = []byte(string(markdown))
 	}
 	cleanedMarkdown := cleanANSI(string(markdown))
 
 	serverURL, err := netURL.QueryUnescape(os.Getenv("UPLOAD_TO_S3_SEVER"))
 	if err != nil {
-		log.Printf("failed to get server url: %v", err)
-		return
+		return "", fmt.Errorf("failed to get server url: %v", err)
 	}
 
 	// HTTP 요청 생성
-	req, err := http.NewRequest("POST", serverURL, bytes.NewBuffer([]byte(cleanedMarkdown)))
+	req, err := http.NewRequestWithContext(ctx, "POST", serverURL, bytes.NewBuffer([]byte(cleanedMarkdown)))
 	if err != nil {
-		log.Printf("failed to create HTTP request: %v", err)
-		return
+		return "", fmt.Errorf("failed to create HTTP request: %v", err)
 	}
-	category = category + "_" + strconv.Itoa(i)
-	req.Header.Set("x-category-sniij", category)
+	req.Header.Set("x-category-sniij", name)
+	req.Header.Set("x-run-id-sniij", runID)
+	if source != "" {
+		req.Header.Set("x-source-sniij", source)
+	}
+	setCorrelationID(req, correlationID)
+	model.SignRequest(req, os.Getenv("SIGNATURE_SECRET"), []byte(cleanedMarkdown))
 
 	// 요청 실행
-	res, err := httpClient.Do(req)
+	res, err := doWithRetry(req, httpAttempts())
 	if err != nil {
-		log.Printf("failed to send HTTP request: %v", err)
-		return
+		return "", fmt.Errorf("failed to send HTTP request: %v", err)
 	}
 	defer res.Body.Close()
 
 	// HTTP 응답 상태 코드 확인
 	if res.StatusCode != http.StatusOK {
-		log.Printf("UploadToS3 server returned status code %d", res.StatusCode)
-		return
+		return "", fmt.Errorf("upload-to-s3 server returned status code %d", res.StatusCode)
 	}
 
 	// 응답 본문 읽기
 	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		log.Printf("failed to read response body: %v", err)
-		return
+		return "", fmt.Errorf("failed to read response body: %v", err)
 	}
 
 	var response S3Response
 	if err := json.Unmarshal(resBody, &response); err != nil {
-		log.Printf("Invalid JSON input: %v", err)
-		return
+		return "", fmt.Errorf("invalid JSON response: %v", err)
 	}
-	log.Printf("S3 msg: %v, filename:%v", response.Message, response.Filename)
+	logger.Info(response.Message, "step", "upload_s3", "id", name, "filename", response.Filename, "status", "ok")
+	return response.Filename, nil
 }
 
-func UploadToGitHub() {
+// UploadToGitHub asks the upload-to-github service to commit today's files.
+func UploadToGitHub(ctx context.Context) error {
 	serverURL, err := netURL.QueryUnescape(os.Getenv("UPLOAD_TO_GITHUB_SERVER"))
 	if err != nil {
-		log.Printf("failed to get server url: %v", err)
-		return
+		return fmt.Errorf("failed to get server url: %v", err)
 	}
 
 	// HTTP 요청 생성
-	req, err := http.NewRequest("GET", serverURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", serverURL, nil)
 	if err != nil {
-		log.Printf("failed to create HTTP request: %v", err)
-		return
+		return fmt.Errorf("failed to create HTTP request: %v", err)
 	}
 
+	model.SignRequest(req, os.Getenv("SIGNATURE_SECRET"), nil)
+
 	// 요청 실행
-	res, err := httpClient.Do(req)
+	res, err := doWithRetry(req, httpAttempts())
 	if err != nil {
-		log.Printf("failed to send HTTP request: %v", err)
-		return
+		return fmt.Errorf("failed to send HTTP request: %v", err)
 	}
 	defer res.Body.Close()
 
 	// HTTP 응답 상태 코드 확인
 	if res.StatusCode != http.StatusOK {
-		log.Printf("UploadToGitHub returned status code %d", res.StatusCode)
-		return
+		return fmt.Errorf("UploadToGitHub returned status code %d", res.StatusCode)
 	}
 
-	log.Printf("Successfully Uploaded to GitHub")
+	logger.Info("uploaded to GitHub", "step", "upload_github", "status", "ok")
+	return nil
 }
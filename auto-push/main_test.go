@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatermarkKeepsOnlyPublishedURLs(t *testing.T) {
+	w := NewWatermark([]string{"https://n.news/a"})
+
+	if !w.Check("https://n.news/a") {
+		t.Errorf("Check(a) = false, want true for a URL published by the previous run")
+	}
+	if w.Check("https://n.news/b") {
+		t.Errorf("Check(b) = true, want false for a new URL")
+	}
+	// b 는 업로드에 실패해 Add 되지 않은 경우
+	w.Add("https://n.news/a")
+	w.Add("https://n.news/c")
+
+	want := []string{"https://n.news/a", "https://n.news/c"}
+	if got := w.URLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("URLs() = %v, want %v", got, want)
+	}
+}
+
+func TestNilWatermark(t *testing.T) {
+	var w *Watermark
+	w.Add("https://n.news/a")
+	if w.Check("https://n.news/a") {
+		t.Errorf("nil Watermark Check = true, want false")
+	}
+}
+
+func TestConvertBatch(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var articles []NewsArticle
+		if err := json.NewDecoder(r.Body).Decode(&articles); err != nil || len(articles) != 2 {
+			t.Errorf("request body = %v articles (%v), want an array of 2", len(articles), err)
+		}
+		w.Header().Set("X-GPT-Tokens", "7")
+		json.NewEncoder(w).Encode([]ConvertResult{
+			{Status: http.StatusOK, Document: "# 첫 기사", Failures: []string{"date"}},
+			{Status: http.StatusUnprocessableEntity, Error: "Article content is empty"},
+		})
+	}))
+	defer server.Close()
+	t.Setenv("CONVERT_SERVER", server.URL)
+
+	conversions := ConvertBatch(context.Background(), []NewsArticle{{Title: "첫 기사"}, {Title: "빈 기사"}}, "")
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1 for the whole batch", requests)
+	}
+	if got := conversions[0]; got.Err != nil || string(got.Markdown) != "# 첫 기사" || !reflect.DeepEqual(got.FailedStages, []string{"date"}) {
+		t.Errorf("conversions[0] = %+v, want the document with failed stage date", got)
+	}
+	if err := conversions[1].Err; err == nil || !strings.Contains(err.Error(), "422") {
+		t.Errorf("conversions[1].Err = %v, want the 422 of the article", err)
+	}
+}
+
+func TestConvertBatchCountMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]ConvertResult{{Status: http.StatusOK, Document: "# 하나"}})
+	}))
+	defer server.Close()
+	t.Setenv("CONVERT_SERVER", server.URL)
+
+	for i, conversion := range ConvertBatch(context.Background(), []NewsArticle{{Title: "a"}, {Title: "b"}}, "") {
+		if conversion.Err == nil {
+			t.Errorf("conversions[%d].Err = nil, want an error for the missing result", i)
+		}
+	}
+}
+
+// fakeDestination accepts every upload and reports Key as the key written.
+type fakeDestination struct {
+	Key string
+}
+
+func (d fakeDestination) Upload(ctx context.Context, markdown []byte, name, source, correlationID string) (string, error) {
+	return d.Key, nil
+}
+
+func (fakeDestination) WriteIndex(ctx context.Context, index []byte) error { return nil }
+
+func (fakeDestination) Publish(ctx context.Context) error { return nil }
+
+func TestProcessArticleIndexesUploadedKey(t *testing.T) {
+	run := &Run{
+		Dest:       fakeDestination{Key: "news/2025-01-02/politics_0.md.gz"},
+		GPTFailure: &FailureReport{},
+		Names:      NewNameRegistry(),
+		Index:      &ArticleIndex{},
+		Outcome:    NewRunOutcome(1),
+	}
+	conversion := Conversion{Markdown: []byte("---\ntitle: \"제목\"\n---\n\n본문")}
+
+	if _, err := processArticle(NewsArticle{Title: "제목"}, "politics", "politics_0", "", conversion, run, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Index.entries) != 1 || run.Index.entries[0].Key != "news/2025-01-02/politics_0.md.gz" {
+		t.Errorf("index entries = %+v, want the key returned by the upload", run.Index.entries)
+	}
+}
+
+func TestProcessArticleIndexesCategoryIcon(t *testing.T) {
+	t.Setenv("CATEGORY_ICONS", `{"politics": "🏛️"}`)
+	run := &Run{
+		Dest:       fakeDestination{Key: "news/2025-01-02/politics_0.md.gz"},
+		GPTFailure: &FailureReport{},
+		Names:      NewNameRegistry(),
+		Index:      &ArticleIndex{},
+		Outcome:    NewRunOutcome(1),
+	}
+	conversion := Conversion{Markdown: []byte("---\ntitle: \"제목\"\n---\n\n본문")}
+
+	if _, err := processArticle(NewsArticle{Title: "제목"}, "politics", "politics_0", "", conversion, run, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Index.entries) != 1 || run.Index.entries[0].Icon != "🏛️" {
+		t.Errorf("index entries = %+v, want the CATEGORY_ICONS icon of politics", run.Index.entries)
+	}
+}
+
+func TestUploadToS3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("x-source-sniij"); got != "https://n.news.naver.com/article/001/0001" {
+			t.Errorf("x-source-sniij = %q, want the article URL", got)
+		}
+		w.Write([]byte(`{"message": "File uploaded successfully", "filename": "news/2025-01-02/politics_0.md"}`))
+	}))
+	defer server.Close()
+	t.Setenv("UPLOAD_TO_S3_SEVER", server.URL)
+
+	key, err := UploadToS3(context.Background(), []byte("# 제목"), "politics_0", "https://n.news.naver.com/article/001/0001", "run", "")
+	if err != nil || key != "news/2025-01-02/politics_0.md" {
+		t.Errorf("UploadToS3 = (%q, %v), want the filename of the response", key, err)
+	}
+}
+
+func TestDeadLetterKeyKeepsRunsApart(t *testing.T) {
+	day := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	first := deadLetterKey(day, "run1", "politics_0")
+	if want := "dlq/2025-01-02/run1/politics_0.json"; first != want {
+		t.Errorf("deadLetterKey = %q, want %q", first, want)
+	}
+	// 같은 날 재실행한 실행의 실패 기사가 앞 실행의 것을 덮어쓰지 않아야 함
+	if second := deadLetterKey(day, "run2", "politics_0"); second == first {
+		t.Errorf("two runs on the same day share the dead letter key %q", first)
+	}
+}
+
+func TestProcessArticlesCountsArticlesSeenInLastRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]NewsArticle{{Title: "어제 기사", URL: "https://n.news/a"}})
+	}))
+	defer server.Close()
+	t.Setenv("CRAWLING_SERVER", server.URL)
+	run := &Run{
+		Dest:       fakeDestination{},
+		Watermark:  NewWatermark([]string{"https://n.news/a"}),
+		GPTFailure: &FailureReport{},
+		Names:      NewNameRegistry(),
+		Outcome:    NewRunOutcome(1),
+	}
+
+	// 새 기사가 없는 증분 실행의 섹션은 실패가 아니라 이미 게시된 기사로 집계
+	converted, seen := processArticles("https://news.naver.com/section/100", "politics", run)
+	if converted != 0 || seen != 1 {
+		t.Errorf("processArticles = (%d, %d), want (0, 1)", converted, seen)
+	}
+	if got := run.Watermark.URLs(); !reflect.DeepEqual(got, []string{"https://n.news/a"}) {
+		t.Errorf("watermark URLs = %v, want the skipped article kept", got)
+	}
+}
+
+func TestUploadToS3AbortsWhenContextIsCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	t.Setenv("UPLOAD_TO_S3_SEVER", server.URL)
+	t.Setenv("HTTP_RETRY_ATTEMPTS", "1")
+
+	// 로컬 실행의 종료 유예 시간이 끝나 진행 중인 업로드를 취소하는 경우
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() {
+		_, err := UploadToS3(ctx, []byte("# 제목"), "politics_0", "", "run", "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+			t.Errorf("UploadToS3 = %v, want the request cancelled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("UploadToS3 kept running after its context was cancelled")
+	}
+}
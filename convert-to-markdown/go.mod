@@ -1,4 +1,4 @@
-module main
+module github.com/Sniij/mircro-services-golang/convert-to-markdown
 
 go 1.23
 
@@ -6,8 +6,11 @@ toolchain go1.23.4
 
 require (
 	github.com/MichaelMure/go-term-markdown v0.1.4
+	github.com/Sniij/mircro-services-golang/model v0.0.0
 	github.com/aws/aws-lambda-go v1.47.0
 	github.com/sashabaranov/go-openai v1.36.1
+	golang.org/x/sync v0.10.0
+	golang.org/x/time v0.8.0
 )
 
 require (
@@ -30,3 +33,5 @@ require (
 	golang.org/x/net v0.0.0-20190813141303-74dc4d7220e7 // indirect
 	golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
 )
+
+replace github.com/Sniij/mircro-services-golang/model => ../model
@@ -5,30 +5,43 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
 	netURL "net/url"
 	"os"
-	"sync"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/Sniij/mircro-services-golang/model"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/joho/godotenv"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
-// NewsArticle represents a news article with title and content.
-type NewsArticle struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	Date    string `json:"date"`
-}
+// NewsArticle is the article exchanged between the services.
+type NewsArticle = model.NewsArticle
+
+// RelatedArticle is a related-story link listed on an article page.
+type RelatedArticle = model.RelatedArticle
+
+// ArticleStats holds length metadata of the converted content.
+type ArticleStats = model.ArticleStats
 
 // GPTRequest represents the payload for the GPT server.
-type GPTRequest struct {
-	Content string `json:"content"`
-	Prompt  string `json:"prompt"`
-}
+type GPTRequest = model.GPTRequest
+
+// ConvertResult is one article of a BATCH_GPT response.
+type ConvertResult = model.ConvertResult
 
 func init() {
 	// .env 파일 로드 (로컬 환경에서만 사용)
@@ -37,10 +50,80 @@ func init() {
 			log.Println("No .env file found. Falling back to system environment variables.")
 		}
 	}
+	gptLimiter = newGPTLimiter()
+	gptClient = newGPTClient()
+}
+
+// gptTokens sums the OpenAI tokens reported by gpt-api during the current invocation
+var gptTokens atomic.Int64
+
+// gptLimiter is a token bucket shared by every GPT call of the process, so the
+// request rate stays under GPT_RPM regardless of concurrency. nil means unlimited.
+var gptLimiter *rate.Limiter
+
+// newGPTLimiter reads GPT_RPM (requests per minute, 0 or unset disables the limit)
+// and GPT_RPM_BURST (default 1).
+func newGPTLimiter() *rate.Limiter {
+	value := os.Getenv("GPT_RPM")
+	if value == "" {
+		return nil
+	}
+	rpm, err := strconv.ParseFloat(value, 64)
+	if err != nil || rpm <= 0 {
+		if err != nil || rpm < 0 {
+			log.Printf("Invalid GPT_RPM %q. Disabling rate limit", value)
+		}
+		return nil
+	}
+	burst := 1
+	if value := os.Getenv("GPT_RPM_BURST"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			burst = n
+		} else {
+			log.Printf("Invalid GPT_RPM_BURST %q. Falling back to 1", value)
+		}
+	}
+	return rate.NewLimiter(rate.Limit(rpm/60), burst)
+}
+
+// gptClient sends the GPT requests, bounded by GPT_TIMEOUT.
+var gptClient *http.Client
+
+// newGPTClient reads GPT_TIMEOUT (a duration, default 60s) as the timeout of a
+// single GPT request including the response body.
+func newGPTClient() *http.Client {
+	timeout := 60 * time.Second
+	if value := os.Getenv("GPT_TIMEOUT"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			timeout = d
+		} else {
+			log.Printf("Invalid GPT_TIMEOUT %q. Falling back to 60s", value)
+		}
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// waitForGPT blocks until the rate limit allows another GPT call or ctx is done.
+func waitForGPT(ctx context.Context) error {
+	if gptLimiter == nil {
+		return nil
+	}
+	if err := gptLimiter.Wait(ctx); err != nil {
+		return fmt.Errorf("GPT rate limit wait failed: %v", err)
+	}
+	return nil
 }
 
-// FetchGPT processes text using the custom GPT server.
-func FetchGPT(gptRequest GPTRequest) (string, error) {
+// FetchGPT processes text using the custom GPT server. It reads the body as the reply
+// text, so gpt-api must run with the default plain-text RESPONSE_FORMAT. Both the
+// rate limit wait and the request are canceled with ctx.
+func FetchGPT(ctx context.Context, gptRequest GPTRequest) (string, error) {
+	defer model.LogTiming("FetchGPT", fmt.Sprintf("chars=%d", len(gptRequest.Content)), time.Now())
+
+	if err := waitForGPT(ctx); err != nil {
+		return "", err
+	}
+
 	serverURL, err := netURL.QueryUnescape(os.Getenv("GPT_SERVER"))
 	if err != nil {
 		return "", fmt.Errorf("failed to get server url: %v", err)
@@ -51,15 +134,15 @@ func FetchGPT(gptRequest GPTRequest) (string, error) {
 		return "", fmt.Errorf("failed to marshal GPT request: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", serverURL, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", serverURL, bytes.NewBuffer(body))
 	if err != nil {
 		return "", fmt.Errorf("failed to create HTTP request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	model.SignRequest(req, os.Getenv("SIGNATURE_SECRET"), body)
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	res, err := gptClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to send HTTP request: %v", err)
 	}
@@ -74,84 +157,1066 @@ func FetchGPT(gptRequest GPTRequest) (string, error) {
 		return "", fmt.Errorf("failed to decode GPT response: %v", err)
 	}
 
+	// 설정 오류로 200 과 함께 에러 페이지가 오는 경우를 정제된 본문으로 취급하지 않도록 검증
+	if os.Getenv("GPT_RESPONSE_CHECK") != "false" {
+		if err := checkGPTResponse(res.Header.Get("Content-Type"), gptResponse); err != nil {
+			return "", err
+		}
+	}
+
+	if tokens, err := strconv.Atoi(res.Header.Get("X-GPT-Tokens")); err == nil {
+		gptTokens.Add(int64(tokens))
+	}
 	return string(gptResponse), nil
 }
 
+// checkGPTResponse rejects responses that look like an error envelope rather than
+// cleaned text: empty or non-UTF-8 bodies, HTML error pages and JSON error objects.
+func checkGPTResponse(contentType string, body []byte) error {
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return fmt.Errorf("GPT server returned an empty response")
+	}
+	if !utf8.Valid(body) {
+		return fmt.Errorf("GPT server returned a non-UTF-8 response")
+	}
+
+	lower := strings.ToLower(text)
+	if strings.HasPrefix(contentType, "text/html") || strings.HasPrefix(lower, "<!doctype html") || strings.HasPrefix(lower, "<html") {
+		return fmt.Errorf("GPT server returned an HTML page instead of content: %.100s", text)
+	}
+
+	// gpt-api 의 에러 응답({"error": ...}) 과 API Gateway 의 에러 응답({"message": ...})
+	var envelope map[string]interface{}
+	if strings.HasPrefix(text, "{") && json.Unmarshal(body, &envelope) == nil {
+		if message, ok := envelope["error"]; ok {
+			return fmt.Errorf("GPT server returned an error body: %v", message)
+		}
+		if message, ok := envelope["message"]; ok && len(envelope) == 1 {
+			return fmt.Errorf("GPT server returned an error body: %v", message)
+		}
+	}
+	return nil
+}
+
 // ConvertToMarkdown converts an article to Markdown format.
 func ConvertToMarkdown(article NewsArticle) []byte {
 	title := fmt.Sprintf("# **제목: %s**", article.Title)
+	if icon := model.CategoryIcon(article.Category); icon != "" {
+		title = fmt.Sprintf("# %s **제목: %s**", icon, article.Title)
+	}
 	content := fmt.Sprintf("내용: %s", article.Content)
 
 	date := fmt.Sprintf("**날짜: %s**", article.Date)
+	if article.RawDate != "" {
+		date += fmt.Sprintf("\n\n  **원본 날짜: %s**", article.RawDate)
+	}
 
-	return []byte(fmt.Sprintf("%s\n\n  %s\n\n  %s", title, content, date))
+	if article.Stats != nil {
+		date += fmt.Sprintf("\n\n  **%s**", statsLine(*article.Stats))
+	}
+	if len(article.Related) > 0 {
+		date += "\n\n  **관련 기사**\n"
+		for _, related := range article.Related {
+			date += fmt.Sprintf("\n  - [%s](%s)", related.Title, related.URL)
+		}
+	}
+
+	body := fmt.Sprintf("%s\n\n  %s\n\n  %s", title, content, date)
+	if os.Getenv("FRONTMATTER") == "true" {
+		body = frontMatter(article) + body
+	}
+	return []byte(body)
 }
 
-// Handler processes the Lambda event.
-func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	var article NewsArticle
-	if err := json.Unmarshal([]byte(request.Body), &article); err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       `{"error": "Invalid JSON input"}`,
-		}, nil
+// frontMatter returns the YAML front matter block of an article with title, date,
+// category, source, raw_date and stats. Values are double-quoted YAML strings so quotes
+// and colons in a title cannot break the parser; unset optional fields are left out.
+func frontMatter(article NewsArticle) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(article.Title))
+	fmt.Fprintf(&b, "date: %s\n", strconv.Quote(article.Date))
+	if article.Category != "" {
+		fmt.Fprintf(&b, "category: %s\n", strconv.Quote(article.Category))
+	}
+	if article.URL != "" {
+		fmt.Fprintf(&b, "source: %s\n", strconv.Quote(article.URL))
+	}
+	if article.RawDate != "" {
+		fmt.Fprintf(&b, "raw_date: %s\n", strconv.Quote(article.RawDate))
+	}
+	if stats := article.Stats; stats != nil {
+		fmt.Fprintf(&b, "stats:\n  characters: %d\n  words: %d\n  reading_time_minutes: %d\n", stats.Characters, stats.Words, stats.ReadingTimeMinutes)
 	}
+	b.WriteString("---\n\n")
+	return b.String()
+}
 
-	var wg sync.WaitGroup
+// Render converts an article to the given format: "markdown" (default), "html" or "txt".
+func Render(article NewsArticle, format string) ([]byte, error) {
+	switch format {
+	case "", "markdown":
+		return ConvertToMarkdown(article), nil
+	case "html":
+		return RenderHTML(article), nil
+	case "txt":
+		return RenderText(article), nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
 
-	wg.Add(2)
+// RenderHTML converts an article to a minimal escaped HTML fragment. With
+// SANITIZE_HTML=true the title and content keep the ALLOWED_HTML_TAGS, see sanitizeHTML;
+// the article passed in must not be sanitized already.
+func RenderHTML(article NewsArticle) []byte {
+	text := html.EscapeString
+	if os.Getenv("SANITIZE_HTML") == "true" {
+		allowed := allowedTags()
+		text = func(s string) string { return sanitizeHTML(s, allowed, html.EscapeString) }
+	}
 
-	go func() {
-		defer wg.Done()
-		cleanedContent, err := FetchGPT(GPTRequest{Content: article.Content, Prompt: os.Getenv("PROMPT_CONTENT_1")})
-		if err != nil {
-			log.Printf("Error processing article with GPT that content: %v", err)
-			return
+	var b strings.Builder
+	b.WriteString("<article>\n")
+	heading := "제목: " + text(article.Title)
+	if icon := model.CategoryIcon(article.Category); icon != "" {
+		heading = html.EscapeString(icon) + " " + heading
+	}
+	fmt.Fprintf(&b, "  <h1>%s</h1>\n", heading)
+	// 문단으로 나누기 전에 정제해야 문단을 넘나드는 script 등도 제거됨
+	for _, paragraph := range strings.Split(text(article.Content), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			fmt.Fprintf(&b, "  <p>%s</p>\n", paragraph)
 		}
-		cleanedContent2, err := FetchGPT(GPTRequest{Content: cleanedContent, Prompt: os.Getenv("PROMPT_CONTENT_2")})
-		if err != nil {
-			log.Printf("Error processing article with GPT that content: %v", err)
-			return
+	}
+	fmt.Fprintf(&b, "  <p><strong>날짜: %s</strong></p>\n", html.EscapeString(article.Date))
+	if article.RawDate != "" {
+		fmt.Fprintf(&b, "  <p><strong>원본 날짜: %s</strong></p>\n", html.EscapeString(article.RawDate))
+	}
+	if article.Stats != nil {
+		fmt.Fprintf(&b, "  <p><strong>%s</strong></p>\n", html.EscapeString(statsLine(*article.Stats)))
+	}
+	if len(article.Related) > 0 {
+		b.WriteString("  <p><strong>관련 기사</strong></p>\n  <ul>\n")
+		for _, related := range article.Related {
+			fmt.Fprintf(&b, "    <li><a href=\"%s\">%s</a></li>\n", html.EscapeString(related.URL), html.EscapeString(related.Title))
+		}
+		b.WriteString("  </ul>\n")
+	}
+	b.WriteString("</article>\n")
+	return []byte(b.String())
+}
+
+var (
+	markdownHeading  = regexp.MustCompile(`(?m)^#{1,6}\s*`)
+	markdownLink     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	markdownEmphasis = regexp.MustCompile("\\*{1,3}|_{2,3}|`")
+)
+
+// stripMarkdown removes headings, emphasis, links and code markers, keeping the text.
+func stripMarkdown(s string) string {
+	s = markdownHeading.ReplaceAllString(s, "")
+	s = markdownLink.ReplaceAllString(s, "$1")
+	return markdownEmphasis.ReplaceAllString(s, "")
+}
+
+// RenderText converts an article to plain text without any markup.
+func RenderText(article NewsArticle) []byte {
+	title := fmt.Sprintf("제목: %s", stripMarkdown(article.Title))
+	content := fmt.Sprintf("내용: %s", stripMarkdown(article.Content))
+	date := fmt.Sprintf("날짜: %s", stripMarkdown(article.Date))
+	if article.RawDate != "" {
+		date += fmt.Sprintf("\n원본 날짜: %s", article.RawDate)
+	}
+	if article.Stats != nil {
+		date += "\n" + statsLine(*article.Stats)
+	}
+	if len(article.Related) > 0 {
+		date += "\n\n관련 기사"
+		for _, related := range article.Related {
+			date += fmt.Sprintf("\n- %s (%s)", related.Title, related.URL)
+		}
+	}
+
+	return []byte(fmt.Sprintf("%s\n\n%s\n\n%s\n", title, content, date))
+}
+
+// contentTypes maps each output format to its response Content-Type.
+var contentTypes = map[string]string{
+	"":         "text/plain",
+	"markdown": "text/plain",
+	"html":     "text/html; charset=utf-8",
+	"txt":      "text/plain; charset=utf-8",
+}
+
+var (
+	// 내용까지 통째로 제거하는 태그
+	dangerousElement = regexp.MustCompile(`(?is)<(script|style|iframe)\b[^>]*>.*?</(script|style|iframe)\s*>|<(script|style|iframe)\b[^>]*/?>`)
+	htmlTag          = regexp.MustCompile(`</?([a-zA-Z][a-zA-Z0-9]*)\b[^>]*>`)
+)
+
+var (
+	headingLine     = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletLine      = regexp.MustCompile(`^(\s*)[*+•·]\s+(.*)$`)
+	orderedLine     = regexp.MustCompile(`^(\s*)(\d{1,3})[.)]\s+(.*)$`)
+	emptyListMarker = regexp.MustCompile(`^\s*(\d{1,3}[.)]|[-*+•·])\s*$`)
+	extraBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// normalizeContent applies normalizeMarkdown to the content when NORMALIZE_MARKDOWN=true.
+func normalizeContent(article *NewsArticle) {
+	if os.Getenv("NORMALIZE_MARKDOWN") == "true" {
+		article.Content = normalizeMarkdown(article.Content)
+	}
+}
+
+// normalizeMarkdown canonicalizes markdown produced by the GPT stages: headings are
+// shifted so the top level is ## (the title is the only #), list bullets become "-",
+// ordered lists are renumbered from 1 with "N." markers, empty list markers are
+// dropped, trailing spaces are trimmed and runs of blank lines collapse to one.
+// A line is an ordered list item only when its 1-3 digit marker is 1 or it follows
+// another item, so prose such as "2025. 1. 4. 오후" or "3. 서울" is left alone.
+func normalizeMarkdown(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+
+	minLevel := 0
+	for _, line := range lines {
+		if m := headingLine.FindStringSubmatch(line); m != nil && m[2] != "" {
+			if minLevel == 0 || len(m[1]) < minLevel {
+				minLevel = len(m[1])
+			}
+		}
+	}
+
+	var out []string
+	number := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if emptyListMarker.MatchString(line) {
+			continue
+		}
+		if m := headingLine.FindStringSubmatch(line); m != nil && m[2] != "" {
+			level := min(len(m[1])-minLevel+2, 6)
+			line = strings.Repeat("#", level) + " " + m[2]
+		} else if m := bulletLine.FindStringSubmatch(line); m != nil {
+			line = m[1] + "- " + m[2]
+		}
+
+		if m := orderedLine.FindStringSubmatch(line); m != nil && (number > 0 || m[2] == "1") {
+			number++
+			line = fmt.Sprintf("%s%d. %s", m[1], number, m[3])
+		} else if line != "" {
+			// 빈 줄은 목록을 끊지 않고, 다른 내용이 나오면 번호를 새로 시작
+			number = 0
+		}
+		out = append(out, line)
+	}
+
+	result := extraBlankLines.ReplaceAllString(strings.Join(out, "\n"), "\n\n")
+	return strings.TrimSpace(result)
+}
+
+// sanitizeArticle escapes HTML in the title and content when SANITIZE_HTML=true.
+// It is for the markdown and txt outputs; RenderHTML sanitizes while it escapes.
+func sanitizeArticle(article *NewsArticle) {
+	if os.Getenv("SANITIZE_HTML") != "true" {
+		return
+	}
+	allowed := allowedTags()
+	article.Title = sanitizeHTML(article.Title, allowed, escapeAngles)
+	article.Content = sanitizeHTML(article.Content, allowed, escapeAngles)
+}
+
+// allowedTags returns the lower-case tag names listed in ALLOWED_HTML_TAGS.
+func allowedTags() map[string]bool {
+	allowed := make(map[string]bool)
+	for _, tag := range strings.Split(os.Getenv("ALLOWED_HTML_TAGS"), ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			allowed[tag] = true
 		}
+	}
+	return allowed
+}
+
+// sanitizeHTML removes script, style and iframe elements with their content, keeps
+// the allowed tags without attributes (e.g. <b>, </b>) and passes the text around
+// them through escape so stray markup renders as text.
+func sanitizeHTML(s string, allowed map[string]bool, escape func(string) string) string {
+	s = dangerousElement.ReplaceAllString(s, "")
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range htmlTag.FindAllStringSubmatchIndex(s, -1) {
+		name := strings.ToLower(s[loc[2]:loc[3]])
+		if !allowed[name] {
+			continue
+		}
+		b.WriteString(escape(s[last:loc[0]]))
+		if strings.HasPrefix(s[loc[0]:], "</") {
+			b.WriteString("</" + name + ">")
+		} else {
+			b.WriteString("<" + name + ">")
+		}
+		last = loc[1]
+	}
+	b.WriteString(escape(s[last:]))
+	return b.String()
+}
 
-		cleanedContent3, err := FetchGPT(GPTRequest{Content: cleanedContent2, Prompt: os.Getenv("PROMPT_CONTENT_3")})
+// escapeAngles escapes < and > as HTML entities.
+func escapeAngles(s string) string {
+	return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// statsLine formats stats for the rendered document.
+func statsLine(stats ArticleStats) string {
+	return fmt.Sprintf("글자 수: %d, 단어 수: %d, 읽는 시간: 약 %d분", stats.Characters, stats.Words, stats.ReadingTimeMinutes)
+}
+
+// addStats computes ArticleStats from the finalized content when ARTICLE_STATS=true.
+// The reading time uses WORDS_PER_MINUTE (default 200) and is at least one minute.
+func addStats(article *NewsArticle) {
+	if os.Getenv("ARTICLE_STATS") != "true" {
+		return
+	}
+	wordsPerMinute := getEnvInt("WORDS_PER_MINUTE", 200)
+	if wordsPerMinute == 0 {
+		wordsPerMinute = 200
+	}
+
+	words := len(strings.Fields(article.Content))
+	minutes := (words + wordsPerMinute - 1) / wordsPerMinute
+	if minutes < 1 {
+		minutes = 1
+	}
+	article.Stats = &ArticleStats{
+		Characters:         utf8.RuneCountInString(article.Content),
+		Words:              words,
+		ReadingTimeMinutes: minutes,
+	}
+}
+
+// keepRawDate copies the scraped date into RawDate before normalization when KEEP_RAW_DATE=true.
+func keepRawDate(article *NewsArticle) {
+	if os.Getenv("KEEP_RAW_DATE") == "true" {
+		article.RawDate = article.Date
+	}
+}
+
+// ConversionStage is an independent enrichment step applied to an article.
+// A failing Fatal stage fails the whole request; a failing non-fatal stage
+// is logged and the article keeps its original value for that field.
+type ConversionStage struct {
+	Name  string
+	Fatal bool
+	Run   func(ctx context.Context, article NewsArticle) (string, error)
+	// Batch runs the stage for several articles at once in BATCH_GPT mode, returning
+	// the result and error Run would have returned for each of them.
+	Batch func(ctx context.Context, articles []NewsArticle) ([]string, []error)
+	Apply func(article *NewsArticle, result string)
+}
+
+// conversionStages returns the stages in the order their results are applied.
+// FATAL_STAGES (comma-separated stage names) marks stages whose failure is fatal.
+func conversionStages() []ConversionStage {
+	fatal := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv("FATAL_STAGES"), ",") {
+		fatal[strings.TrimSpace(name)] = true
+	}
+
+	return []ConversionStage{
+		{
+			Name:  "content",
+			Fatal: fatal["content"],
+			Run:   cleanContent,
+			Batch: batchCleanContent,
+			Apply: func(article *NewsArticle, result string) { article.Content = result },
+		},
+		{
+			Name:  "date",
+			Fatal: fatal["date"],
+			Run:   cleanDate,
+			Batch: batchCleanDate,
+			Apply: func(article *NewsArticle, result string) { article.Date = result },
+		},
+	}
+}
+
+// datePrompt asks GPT to keep a single normalized timestamp.
+const datePrompt = "다음 텍스트에서 날짜가 여러개 있으면 앞에 것만 선택해서 한 날짜만 남게 해주고, 'yyyy년 mm월 dd일 hh시 mm분' 포맷으로 수정해주세요. 예를 들어 '2025년 01월 04일 오후 3시 25분2025년 01월 04일 오후 4시 08분' 이런식으로 있다면 '2025년 01월 04일 오후 3시 25분'만 남게 해주세요."
+
+// contentPrompts returns the chained content cleaning prompts in order: the JSON
+// array in PROMPT_CONTENT_STEPS, or else PROMPT_CONTENT_1, PROMPT_CONTENT_2, ...
+// up to the first unset index. No prompts leaves the content unchanged.
+func contentPrompts() []string {
+	if steps := os.Getenv("PROMPT_CONTENT_STEPS"); steps != "" {
+		var prompts []string
+		err := json.Unmarshal([]byte(steps), &prompts)
+		if err == nil {
+			return prompts
+		}
+		log.Printf("Invalid PROMPT_CONTENT_STEPS: %v. Falling back to PROMPT_CONTENT_<n>", err)
+	}
+
+	var prompts []string
+	for i := 1; ; i++ {
+		prompt := os.Getenv(fmt.Sprintf("PROMPT_CONTENT_%d", i))
+		if prompt == "" {
+			return prompts
+		}
+		prompts = append(prompts, prompt)
+	}
+}
+
+// cleanContent runs the content through the chained cleaning prompts, each prompt
+// receiving the output of the previous one. When a later prompt fails, the output
+// of the last successful prompt is kept; only a failing first prompt is an error.
+func cleanContent(ctx context.Context, article NewsArticle) (string, error) {
+	content := article.Content
+	for i, prompt := range contentPrompts() {
+		cleaned, err := FetchGPT(ctx, GPTRequest{Content: content, Prompt: prompt})
+		if err == nil && strings.TrimSpace(cleaned) == "" {
+			err = fmt.Errorf("empty GPT output")
+		}
+		if err == nil {
+			err = checkPlausible(content, cleaned)
+		}
 		if err != nil {
-			log.Printf("Error processing article with GPT that content: %v", err)
-			return
+			if i == 0 {
+				return "", err
+			}
+			log.Printf("Content prompt %d failed, keeping the output of prompt %d: %v", i+1, i, err)
+			return content, nil
+		}
+		content = cleaned
+	}
+	return content, nil
+}
+
+// checkPlausible rejects cleaned content whose length is far from the input, a sign
+// the model followed instructions embedded in the article instead of cleaning it.
+// It applies when OUTPUT_PLAUSIBILITY=true, with the accepted output/input rune ratio
+// set by OUTPUT_MIN_RATIO (default 0.3) and OUTPUT_MAX_RATIO (default 1.5).
+func checkPlausible(input, output string) error {
+	if os.Getenv("OUTPUT_PLAUSIBILITY") != "true" {
+		return nil
+	}
+	inputLen := utf8.RuneCountInString(strings.TrimSpace(input))
+	if inputLen == 0 {
+		return nil
+	}
+	ratio := float64(utf8.RuneCountInString(strings.TrimSpace(output))) / float64(inputLen)
+	minRatio := getEnvFloat("OUTPUT_MIN_RATIO", 0.3)
+	maxRatio := getEnvFloat("OUTPUT_MAX_RATIO", 1.5)
+	if ratio < minRatio || ratio > maxRatio {
+		return fmt.Errorf("implausible GPT output: length ratio %.2f outside [%.2f, %.2f]", ratio, minRatio, maxRatio)
+	}
+	return nil
+}
+
+// dateBackoff is the wait before the second GPT date attempt. Tests may shorten it.
+var dateBackoff = time.Second
+
+// cleanDate normalizes the scraped date string to a single timestamp. With
+// DATE_PREPARSE=true unambiguous dates are parsed locally without GPT. Otherwise GPT
+// is tried DATE_GPT_ATTEMPTS times (default 2) with a doubling backoff, independently
+// of the content stage, and parseDate is used when every attempt fails or ctx ends
+// while waiting to retry.
+func cleanDate(ctx context.Context, article NewsArticle) (string, error) {
+	if os.Getenv("DATE_PREPARSE") == "true" {
+		if date, ok := preparseDate(article.Date); ok {
+			return date, nil
+		}
+	}
+
+	attempts := getEnvInt("DATE_GPT_ATTEMPTS", 2)
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := dateBackoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var date string
+		date, err = FetchGPT(ctx, GPTRequest{Content: article.Date, Prompt: datePrompt})
+		if err == nil {
+			return date, nil
+		}
+		if attempt < attempts {
+			log.Printf("Date attempt %d/%d failed: %v", attempt, attempts, err)
+			select {
+			case <-ctx.Done():
+				// 남은 시간이 없으면 재시도하지 않고 로컬 파서로 처리
+				return fallbackDate(article.Date, err)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
 		}
+	}
+	return fallbackDate(article.Date, err)
+}
 
-		article.Content = cleanedContent3
-	}()
-	go func() {
-		defer wg.Done()
-		cleanedDate, err := FetchGPT(GPTRequest{Content: article.Date, Prompt: "다음 텍스트에서 날짜가 여러개 있으면 앞에 것만 선택해서 한 날짜만 남게 해주고, 'yyyy년 mm월 dd일 hh시 mm분' 포맷으로 수정해주세요. 예를 들어 '2025년 01월 04일 오후 3시 25분2025년 01월 04일 오후 4시 08분' 이런식으로 있다면 '2025년 01월 04일 오후 3시 25분'만 남게 해주세요."})
+// fallbackDate parses raw locally after GPT failed to normalize it with gptErr.
+func fallbackDate(raw string, gptErr error) (string, error) {
+	date, parseErr := parseDate(raw)
+	if parseErr != nil {
+		return "", fmt.Errorf("GPT date normalization failed: %v; %v", gptErr, parseErr)
+	}
+	log.Printf("GPT date normalization failed, used local parser: %v", gptErr)
+	return date, nil
+}
+
+// dateTimestamp matches the first timestamp of the Naver templates, e.g.
+// "2025.01.04. 오후 3:25", "2025-01-04 15:25:00" or "2025-01-04T15:25:00+09:00".
+var dateTimestamp = regexp.MustCompile(`(\d{4})[.-](\d{1,2})[.-](\d{1,2})\.?\s*T?\s*(오전|오후)?\s*(\d{1,2}):(\d{2})`)
+
+// parseDate formats the first timestamp in raw like datePrompt asks GPT to,
+// e.g. "2025년 01월 04일 오후 3시 25분".
+func parseDate(raw string) (string, error) {
+	m := dateTimestamp.FindStringSubmatch(raw)
+	if m == nil {
+		return "", fmt.Errorf("no timestamp found in %q", raw)
+	}
+	return formatTimestamp(m)
+}
+
+// preparseDate parses raw without GPT when it is unambiguous: it holds at least one
+// recognized timestamp and at most DATE_MAX_TIMESTAMPS (default 1) distinct ones.
+// Naver's "입력 ... 수정 ..." pair counts as two, so set 2 to take the first of them.
+func preparseDate(raw string) (string, bool) {
+	var first string
+	distinct := make(map[string]bool)
+	for _, m := range dateTimestamp.FindAllStringSubmatch(raw, -1) {
+		date, err := formatTimestamp(m)
 		if err != nil {
-			log.Printf("Error processing article with GPT that date: %v", err)
-			return
+			return "", false
+		}
+		if first == "" {
+			first = date
+		}
+		distinct[date] = true
+	}
+	if first == "" || len(distinct) > getEnvInt("DATE_MAX_TIMESTAMPS", 1) {
+		return "", false
+	}
+	return first, true
+}
+
+// formatTimestamp formats a dateTimestamp match.
+func formatTimestamp(m []string) (string, error) {
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	hour, _ := strconv.Atoi(m[5])
+	minute, _ := strconv.Atoi(m[6])
+
+	// 오전/오후 표기가 없으면 24시간제
+	meridiem := m[4]
+	if meridiem == "" {
+		meridiem = "오전"
+		if hour >= 12 {
+			meridiem = "오후"
+		}
+		if hour > 12 {
+			hour -= 12
+		}
+		if hour == 0 {
+			hour = 12
+		}
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 12 || minute > 59 {
+		return "", fmt.Errorf("invalid timestamp %q", m[0])
+	}
+	return fmt.Sprintf("%d년 %02d월 %02d일 %s %d시 %02d분", year, month, day, meridiem, hour, minute), nil
+}
+
+// restoreEmptyContent puts back the original content when the conversion left the
+// article body empty. It reports false when the original is empty as well.
+func restoreEmptyContent(article *NewsArticle, original string) bool {
+	if strings.TrimSpace(article.Content) != "" {
+		return true
+	}
+	if strings.TrimSpace(original) == "" {
+		return false
+	}
+	log.Printf("Conversion left %q empty. Keeping the original content", article.Title)
+	article.Content = original
+	return true
+}
+
+// RunStages runs the stages concurrently, at most limit at a time (0 means
+// unbounded), and applies the successful results to the article in stage order.
+// It also returns the names of the non-fatal stages that failed.
+func RunStages(ctx context.Context, article NewsArticle, stages []ConversionStage, limit int) (NewsArticle, []string, error) {
+	results := make([]string, len(stages))
+	succeeded := make([]bool, len(stages))
+
+	var g errgroup.Group
+	if limit > 0 {
+		g.SetLimit(limit)
+	}
+	for i, stage := range stages {
+		g.Go(func() error {
+			result, err := stage.Run(ctx, article)
+			if err != nil {
+				if stage.Fatal {
+					return fmt.Errorf("%s stage failed: %v", stage.Name, err)
+				}
+				log.Printf("Error processing article with GPT that %s: %v", stage.Name, err)
+				return nil
+			}
+			results[i] = result
+			succeeded[i] = true
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return article, nil, err
+	}
+
+	var failed []string
+	for i, stage := range stages {
+		if succeeded[i] {
+			stage.Apply(&article, results[i])
+		} else {
+			failed = append(failed, stage.Name)
+		}
+	}
+	return article, failed, nil
+}
+
+// batchMarker labels each article in a batched GPT request and response.
+var batchMarker = regexp.MustCompile(`(?m)^[ \t]*===[ \t]*ARTICLE[ \t]+(\d+)[ \t]*===[ \t]*$`)
+
+// batchFetchGPT runs prompt over several inputs in a single GPT call. Sections the
+// model drops or mislabels are retried one by one; errs[i] is set when input i failed.
+func batchFetchGPT(ctx context.Context, prompt string, inputs []string) ([]string, []error) {
+	var b strings.Builder
+	for i, input := range inputs {
+		fmt.Fprintf(&b, "===ARTICLE %d===\n%s\n", i+1, input)
+	}
+	batchPrompt := fmt.Sprintf("%s\n\n아래에는 %d개의 기사가 '===ARTICLE 번호===' 구분자로 나뉘어 있습니다. 각 기사를 따로 처리하고, 결과도 같은 구분자와 번호를 그대로 유지해서 기사 순서대로 반환해주세요", prompt, len(inputs))
+
+	results := make([]string, len(inputs))
+	ok := make([]bool, len(inputs))
+	errs := make([]error, len(inputs))
+	response, err := FetchGPT(ctx, GPTRequest{Content: b.String(), Prompt: batchPrompt})
+	if err != nil {
+		log.Printf("Error processing batch with GPT: %v", err)
+	} else {
+		results, ok = parseBatchResponse(response, len(inputs))
+	}
+
+	// 누락되거나 번호가 잘못된 기사는 개별 요청으로 복구
+	for i, input := range inputs {
+		if ok[i] {
+			continue
+		}
+		results[i], errs[i] = FetchGPT(ctx, GPTRequest{Content: input, Prompt: prompt})
+		if errs[i] != nil {
+			log.Printf("Error processing article %d of batch with GPT: %v", i+1, errs[i])
+		}
+	}
+	return results, errs
+}
+
+// parseBatchResponse splits a batched GPT response into n sections. Sections are
+// matched by their label; when the labels are unusable but the section count is
+// exactly n, sections are taken in order. Unrecovered sections have ok[i] false.
+func parseBatchResponse(response string, n int) ([]string, []bool) {
+	results := make([]string, n)
+	ok := make([]bool, n)
+
+	locs := batchMarker.FindAllStringSubmatchIndex(response, -1)
+	sections := make([]string, len(locs))
+	labels := make([]int, len(locs))
+	for k, loc := range locs {
+		end := len(response)
+		if k+1 < len(locs) {
+			end = locs[k+1][0]
+		}
+		sections[k] = strings.TrimSpace(response[loc[1]:end])
+		labels[k], _ = strconv.Atoi(response[loc[2]:loc[3]])
+	}
+
+	matched := 0
+	for k, label := range labels {
+		if label >= 1 && label <= n && !ok[label-1] && sections[k] != "" {
+			results[label-1] = sections[k]
+			ok[label-1] = true
+			matched++
+		}
+	}
+
+	// 번호가 틀렸어도 개수가 정확히 맞으면 순서대로 사용
+	if matched < n && len(sections) == n {
+		for k, section := range sections {
+			results[k] = section
+			ok[k] = section != ""
+		}
+	}
+	return results, ok
+}
+
+// batchCleanContent is cleanContent for several articles, sending each prompt for all
+// of them in one GPT call. An article drops out of the chain at its first failing
+// prompt and keeps the output of the prompt before, as in cleanContent.
+func batchCleanContent(ctx context.Context, articles []NewsArticle) ([]string, []error) {
+	contents := make([]string, len(articles))
+	errs := make([]error, len(articles))
+	pending := make([]int, len(articles))
+	for i, article := range articles {
+		contents[i] = article.Content
+		pending[i] = i
+	}
+
+	for step, prompt := range contentPrompts() {
+		if len(pending) == 0 {
+			break
+		}
+		inputs := make([]string, len(pending))
+		for k, i := range pending {
+			inputs[k] = contents[i]
+		}
+		results, batchErrs := batchFetchGPT(ctx, prompt, inputs)
+
+		var next []int
+		for k, i := range pending {
+			err := batchErrs[k]
+			if err == nil && strings.TrimSpace(results[k]) == "" {
+				err = fmt.Errorf("empty GPT output")
+			}
+			if err == nil {
+				err = checkPlausible(contents[i], results[k])
+			}
+			if err != nil {
+				if step == 0 {
+					errs[i] = err
+				} else {
+					log.Printf("Content prompt %d failed for %q, keeping the output of prompt %d: %v", step+1, articles[i].Title, step, err)
+				}
+				continue
+			}
+			contents[i] = results[k]
+			next = append(next, i)
+		}
+		pending = next
+	}
+	return contents, errs
+}
+
+// batchCleanDate is cleanDate for several articles: dates DATE_PREPARSE resolves are
+// kept, the others are normalized in one GPT call, and those GPT fails on are parsed
+// locally as in cleanDate.
+func batchCleanDate(ctx context.Context, articles []NewsArticle) ([]string, []error) {
+	dates := make([]string, len(articles))
+	errs := make([]error, len(articles))
+	var pending []int
+	for i, article := range articles {
+		if os.Getenv("DATE_PREPARSE") == "true" {
+			if date, ok := preparseDate(article.Date); ok {
+				dates[i] = date
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return dates, errs
+	}
+
+	inputs := make([]string, len(pending))
+	for k, i := range pending {
+		inputs[k] = articles[i].Date
+	}
+	results, batchErrs := batchFetchGPT(ctx, datePrompt, inputs)
+	for k, i := range pending {
+		if batchErrs[k] != nil {
+			dates[i], errs[i] = fallbackDate(articles[i].Date, batchErrs[k])
+			continue
+		}
+		dates[i] = results[k]
+	}
+	return dates, errs
+}
+
+// RunBatch runs the Batch of each stage over every batchSize articles and returns,
+// for each article, the stages with Run answering the batched result. RunStages then
+// applies them as in single-article mode, with the same fatal and failed stages.
+func RunBatch(ctx context.Context, articles []NewsArticle, stages []ConversionStage, batchSize int) [][]ConversionStage {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	batched := make([][]ConversionStage, len(articles))
+	for i := range articles {
+		batched[i] = slices.Clone(stages)
+	}
+	for start := 0; start < len(articles); start += batchSize {
+		end := min(start+batchSize, len(articles))
+		for s, stage := range stages {
+			if stage.Batch == nil {
+				continue
+			}
+			results, errs := stage.Batch(ctx, articles[start:end])
+			for k := range results {
+				result, err := results[k], errs[k]
+				batched[start+k][s].Run = func(context.Context, NewsArticle) (string, error) {
+					return result, err
+				}
+			}
+		}
+	}
+	return batched
+}
+
+// conversionError is a conversion that failed with an HTTP status.
+type conversionError struct {
+	status  int
+	message string
+}
+
+// response returns the error response of the single-article mode.
+func (e *conversionError) response() events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode: e.status,
+		Body:       model.JSONBody(map[string]interface{}{"error": e.message}),
+	}
+}
+
+// screenArticle prepares the article before any GPT call and returns the stages to
+// run on it: none when LANGUAGE_CHECK=flag flagged it as not primarily Korean. With
+// LANGUAGE_CHECK=skip such articles fail with 422.
+func screenArticle(article *NewsArticle, stages []ConversionStage) ([]ConversionStage, *conversionError) {
+	keepRawDate(article)
+
+	// 한국어 위주가 아닌 기사(영문 통신 기사, 숫자 표 등)는 GPT 호출 전에 걸러냄
+	if mode := os.Getenv("LANGUAGE_CHECK"); mode == "skip" || mode == "flag" {
+		ratio := koreanRatio(article.Content)
+		minRatio := getEnvFloat("MIN_KOREAN_RATIO", 0.5)
+		if ratio < minRatio {
+			log.Printf("Article %q is not primarily Korean (ratio %.2f < %.2f)", article.Title, ratio, minRatio)
+			if mode == "skip" {
+				return nil, &conversionError{http.StatusUnprocessableEntity, fmt.Sprintf("Article is not primarily Korean (ratio %.2f)", ratio)}
+			}
+			// flag 모드에서는 GPT 단계 없이 원문 그대로 변환
+			return nil, nil
 		}
-		article.Date = cleanedDate
-	}()
+	}
+	return stages, nil
+}
 
-	wg.Wait()
+// convertArticle runs the stages on a screened article and renders it in format. It
+// returns the document and the names of the non-fatal stages that failed.
+func convertArticle(ctx context.Context, article NewsArticle, stages []ConversionStage, format string) ([]byte, []string, *conversionError) {
+	original := article.Content
+	article, failedStages, err := RunStages(ctx, article, stages, getEnvInt("CONVERT_CONCURRENCY", 0))
+	if err != nil {
+		log.Printf("Error converting article: %v", err)
+		return nil, nil, &conversionError{http.StatusInternalServerError, fmt.Sprintf("Failed to convert article: %v", err)}
+	}
 
-	markdown := ConvertToMarkdown(article)
+	normalizeContent(&article)
+	// html 은 RenderHTML 이 이스케이프하면서 정제하므로 정제 결과는 markdown, txt 에만 사용
+	// 정제 후 본문이 비는지는 모든 형식에서 같은 기준으로 확인
+	sanitized := article
+	sanitizeArticle(&sanitized)
+	if strings.TrimSpace(sanitized.Content) == "" {
+		article.Content = ""
+	}
+	if format != "html" {
+		article = sanitized
+	}
+	// 빈 본문으로 200 을 돌려주지 않도록 원문으로 대체, 원문도 비어 있으면 실패 처리
+	if !restoreEmptyContent(&article, original) {
+		return nil, nil, &conversionError{http.StatusUnprocessableEntity, "Article content is empty"}
+	}
+	addStats(&article)
+	document, err := Render(article, format)
+	if err != nil {
+		return nil, nil, &conversionError{http.StatusBadRequest, err.Error()}
+	}
+	if len(document) == 0 {
+		return nil, nil, &conversionError{http.StatusInternalServerError, "No articles processed"}
+	}
+	return document, failedStages, nil
+}
 
-	if len(markdown) == 0 {
+// handleBatch converts a JSON array of articles and responds with a JSON array of
+// ConvertResult in the same order. Every article goes through screenArticle and
+// convertArticle as in single-article mode, with the GPT stages batched by RunBatch.
+func handleBatch(ctx context.Context, body string, format string) (events.APIGatewayProxyResponse, error) {
+	var articles []NewsArticle
+	if err := json.Unmarshal([]byte(body), &articles); err != nil {
 		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       `{"error": "No articles processed"}`,
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"error": "Invalid JSON input"}`,
 		}, nil
 	}
 
+	results := make([]ConvertResult, len(articles))
+	stages := make([][]ConversionStage, len(articles))
+	var gpt []int
+	for i := range articles {
+		var failure *conversionError
+		stages[i], failure = screenArticle(&articles[i], conversionStages())
+		if failure != nil {
+			results[i] = ConvertResult{Status: failure.status, Error: failure.message}
+			continue
+		}
+		if len(stages[i]) > 0 {
+			gpt = append(gpt, i)
+		}
+	}
+
+	// GPT 단계가 필요한 기사만 묶어서 처리
+	batchArticles := make([]NewsArticle, len(gpt))
+	for k, i := range gpt {
+		batchArticles[k] = articles[i]
+	}
+	for k, batched := range RunBatch(ctx, batchArticles, conversionStages(), getEnvInt("BATCH_SIZE", 5)) {
+		stages[gpt[k]] = batched
+	}
+
+	for i, article := range articles {
+		if results[i].Status != 0 {
+			continue
+		}
+		document, failedStages, failure := convertArticle(ctx, article, stages[i], format)
+		if failure != nil {
+			results[i] = ConvertResult{Status: failure.status, Error: failure.message}
+			continue
+		}
+		results[i] = ConvertResult{Status: http.StatusOK, Document: string(document), Failures: failedStages}
+	}
+
+	responseBody, err := json.Marshal(results)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to encoding JSON: %v", err)}),
+		}, nil
+	}
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
-		Body:       string(markdown),
+		Body:       string(responseBody),
 		Headers: map[string]string{
-			"Content-Type": "text/plain",
+			"Content-Type": "application/json",
+			"X-GPT-Tokens": strconv.FormatInt(gptTokens.Load(), 10),
 		},
 	}, nil
 }
 
+// getEnvInt reads an integer env var, returning fallback when unset or invalid.
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q. Falling back to %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getEnvFloat reads a float env var, returning fallback when unset or invalid.
+func getEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		log.Printf("Invalid %s %q. Falling back to %v", key, value, fallback)
+		return fallback
+	}
+	return f
+}
+
+// koreanRatio returns the share of Hangul among the letters and digits in s,
+// so English snippets and mostly-numeric tables both score low.
+func koreanRatio(s string) float64 {
+	var hangul, total int
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+			total++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(hangul) / float64(total)
+}
+
+// Handler processes the Lambda event.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// auto-push 가 보낸 상관관계 ID 를 이번 호출의 모든 로그에 붙여 서비스 간 추적
+	if id := request.Headers["x-correlation-id"]; id != "" {
+		log.SetPrefix("correlation_id=" + id + " ")
+		defer log.SetPrefix("")
+	}
+	if !model.VerifySignature(request.Headers, []byte(request.Body), os.Getenv) {
+		log.Printf("Rejected request with invalid signature")
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusUnauthorized,
+			Body:       `{"error": "Invalid signature"}`,
+		}, nil
+	}
+
+	gptTokens.Store(0)
+
+	format := request.QueryStringParameters["format"]
+	if _, ok := contentTypes[format]; !ok {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Unsupported format: %s", format)}),
+		}, nil
+	}
+
+	// BATCH_GPT 모드에서는 기사 배열을 받아 여러 기사를 한 번의 GPT 호출로 처리
+	if os.Getenv("BATCH_GPT") == "true" && strings.HasPrefix(strings.TrimSpace(request.Body), "[") {
+		return handleBatch(ctx, request.Body, format)
+	}
+
+	var article NewsArticle
+	if err := json.Unmarshal([]byte(request.Body), &article); err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"error": "Invalid JSON input"}`,
+		}, nil
+	}
+
+	stages, failure := screenArticle(&article, conversionStages())
+	if failure != nil {
+		return failure.response(), nil
+	}
+	markdown, failedStages, failure := convertArticle(ctx, article, stages, format)
+	if failure != nil {
+		return failure.response(), nil
+	}
+
+	headers := map[string]string{
+		"Content-Type": contentTypes[format],
+		"X-GPT-Tokens": strconv.FormatInt(gptTokens.Load(), 10),
+	}
+	// 실패한 GPT 단계를 호출자에게 알려 실행 단위로 집계할 수 있게 함
+	if len(failedStages) > 0 {
+		headers["X-GPT-Failures"] = strings.Join(failedStages, ",")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(markdown),
+		Headers:    headers,
+	}, nil
+}
+
 func main() {
 	lambda.Start(Handler)
 }
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"golang.org/x/time/rate"
+)
+
+func TestFetchGPTWaitsForRateLimitWithContext(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("정제된 본문"))
+	}))
+	defer server.Close()
+	t.Setenv("GPT_SERVER", server.URL)
+
+	// 분당 1회, 첫 호출이 토큰을 소진하면 두 번째 호출은 ctx 만료까지 대기
+	gptLimiter = rate.NewLimiter(rate.Limit(1.0/60), 1)
+	defer func() { gptLimiter = nil }()
+
+	if _, err := FetchGPT(context.Background(), GPTRequest{Content: "본문"}); err != nil {
+		t.Fatalf("first FetchGPT: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := FetchGPT(ctx, GPTRequest{Content: "본문"}); err == nil {
+		t.Fatal("second FetchGPT succeeded, want a rate limit error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("second FetchGPT returned after %v, want it to stop at the context deadline", elapsed)
+	}
+	if calls != 1 {
+		t.Errorf("GPT server calls = %d, want 1", calls)
+	}
+}
+
+func TestFetchGPTCanceledRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	t.Setenv("GPT_SERVER", server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := FetchGPT(ctx, GPTRequest{Content: "본문"})
+	if err == nil {
+		t.Fatal("FetchGPT succeeded, want the context deadline error")
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("context error = %v, want the deadline to have passed", ctx.Err())
+	}
+}
+
+func TestNewGPTClientTimeout(t *testing.T) {
+	t.Setenv("GPT_TIMEOUT", "5s")
+	if got := newGPTClient().Timeout; got != 5*time.Second {
+		t.Errorf("timeout = %v, want 5s", got)
+	}
+	t.Setenv("GPT_TIMEOUT", "soon")
+	if got := newGPTClient().Timeout; got != 60*time.Second {
+		t.Errorf("timeout with invalid GPT_TIMEOUT = %v, want 60s", got)
+	}
+}
+
+// newGPTServer starts a stand-in for gpt-api answering each request with reply and
+// points GPT_SERVER at it. A reply error is answered with status 500.
+func newGPTServer(t *testing.T, reply func(request GPTRequest) (string, error)) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request GPTRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		text, err := reply(request)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(text))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("GPT_SERVER", server.URL)
+}
+
+// echoGPT returns the content unchanged, keeping the batch markers.
+func echoGPT(request GPTRequest) (string, error) {
+	return request.Content, nil
+}
+
+// convertBatch sends articles to Handler in BATCH_GPT mode and decodes the results.
+func convertBatch(t *testing.T, articles []NewsArticle) []ConvertResult {
+	t.Helper()
+	t.Setenv("BATCH_GPT", "true")
+	body, err := json.Marshal(articles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Handler = (%d %s, %v), want 200", response.StatusCode, response.Body, err)
+	}
+	var results []ConvertResult
+	if err := json.Unmarshal([]byte(response.Body), &results); err != nil {
+		t.Fatalf("batch response %s: %v", response.Body, err)
+	}
+	if len(results) != len(articles) {
+		t.Fatalf("results = %d, want %d", len(results), len(articles))
+	}
+	return results
+}
+
+func TestHandleBatchRejectsLikeSingleArticle(t *testing.T) {
+	newGPTServer(t, echoGPT)
+	t.Setenv("PROMPT_CONTENT_1", "정리해주세요")
+	t.Setenv("LANGUAGE_CHECK", "skip")
+
+	results := convertBatch(t, []NewsArticle{
+		{Title: "정상", Content: "한국어로 작성된 기사 본문입니다.", Date: "2025.01.04. 오후 3:25"},
+		{Title: "wire", Content: "An English wire story.", Date: "2025.01.04. 오후 3:25"},
+	})
+	if results[0].Status != http.StatusOK || !strings.Contains(results[0].Document, "한국어로 작성된 기사 본문입니다.") {
+		t.Errorf("Korean article = %+v, want 200 with its content", results[0])
+	}
+	if results[1].Status != http.StatusUnprocessableEntity {
+		t.Errorf("English article = %+v, want 422 from LANGUAGE_CHECK", results[1])
+	}
+}
+
+func TestHandleBatchEmptyContent(t *testing.T) {
+	newGPTServer(t, echoGPT)
+
+	results := convertBatch(t, []NewsArticle{{Title: "빈 기사", Date: "2025.01.04. 오후 3:25"}})
+	if results[0].Status != http.StatusUnprocessableEntity || results[0].Error != "Article content is empty" {
+		t.Errorf("empty article = %+v, want 422 Article content is empty", results[0])
+	}
+}
+
+func TestHandleBatchStageFailures(t *testing.T) {
+	// 내용 정제는 항상 실패, 날짜는 그대로 반환
+	newGPTServer(t, func(request GPTRequest) (string, error) {
+		if strings.HasPrefix(request.Prompt, datePrompt) {
+			return request.Content, nil
+		}
+		return "", errors.New("unavailable")
+	})
+	t.Setenv("PROMPT_CONTENT_1", "정리해주세요")
+	articles := []NewsArticle{{Title: "기사", Content: "본문", Date: "2025.01.04. 오후 3:25"}}
+
+	results := convertBatch(t, articles)
+	if results[0].Status != http.StatusOK || !slices.Equal(results[0].Failures, []string{"content"}) {
+		t.Errorf("non-fatal content failure = %+v, want 200 with failures [content]", results[0])
+	}
+
+	t.Setenv("FATAL_STAGES", "content")
+	results = convertBatch(t, articles)
+	if results[0].Status != http.StatusInternalServerError || !strings.Contains(results[0].Error, "content stage failed") {
+		t.Errorf("fatal content failure = %+v, want 500 content stage failed", results[0])
+	}
+}
+
+func TestHandleBatchDateFallback(t *testing.T) {
+	newGPTServer(t, func(GPTRequest) (string, error) { return "", errors.New("unavailable") })
+
+	results := convertBatch(t, []NewsArticle{{Title: "기사", Content: "본문", Date: "2025.01.04. 오후 3:25"}})
+	if results[0].Status != http.StatusOK || !strings.Contains(results[0].Document, "날짜: 2025년 01월 04일 오후 3시 25분") {
+		t.Errorf("date fallback = %+v, want the locally parsed date", results[0])
+	}
+}
+
+func TestHandleBatchDatePreparse(t *testing.T) {
+	newGPTServer(t, func(request GPTRequest) (string, error) {
+		t.Errorf("unexpected GPT call with prompt %q", request.Prompt)
+		return "", errors.New("unexpected")
+	})
+	t.Setenv("DATE_PREPARSE", "true")
+
+	results := convertBatch(t, []NewsArticle{{Title: "기사", Content: "본문", Date: "2025-01-04 15:25:00"}})
+	if results[0].Status != http.StatusOK || !strings.Contains(results[0].Document, "날짜: 2025년 01월 04일 오후 3시 25분") {
+		t.Errorf("preparsed date = %+v, want it parsed without GPT", results[0])
+	}
+}
+
+func TestFrontMatterIncludesRawDateAndStats(t *testing.T) {
+	t.Setenv("FRONTMATTER", "true")
+	article := NewsArticle{
+		Title:   `예산안 "통과"`,
+		Content: "정부 예산안이 통과했다.",
+		Date:    "2025-01-02",
+		URL:     "https://n.news.naver.com/mnews/article/001/0015000001",
+		RawDate: "2025.01.02. 오전 9:00",
+		Stats:   &ArticleStats{Characters: 13, Words: 3, ReadingTimeMinutes: 1},
+	}
+	want := "---\n" +
+		"title: \"예산안 \\\"통과\\\"\"\n" +
+		"date: \"2025-01-02\"\n" +
+		"source: \"https://n.news.naver.com/mnews/article/001/0015000001\"\n" +
+		"raw_date: \"2025.01.02. 오전 9:00\"\n" +
+		"stats:\n  characters: 13\n  words: 3\n  reading_time_minutes: 1\n" +
+		"---\n\n"
+	if got := string(ConvertToMarkdown(article)); !strings.HasPrefix(got, want) {
+		t.Errorf("front matter =\n%s\nwant\n%s", got, want)
+	}
+
+	article.RawDate, article.Stats = "", nil
+	got := frontMatter(article)
+	if strings.Contains(got, "raw_date") || strings.Contains(got, "stats") {
+		t.Errorf("front matter without raw date and stats =\n%s", got)
+	}
+}
+
+func TestCleanDateFallbackChain(t *testing.T) {
+	dateBackoff = time.Millisecond
+	t.Cleanup(func() { dateBackoff = time.Second })
+	t.Setenv("DATE_GPT_ATTEMPTS", "3")
+	const normalized = "2025년 01월 04일 오후 3시 25분"
+
+	tests := []struct {
+		name      string
+		raw       string
+		failures  int // GPT 가 먼저 실패하는 횟수
+		want      string
+		wantCalls int
+		wantErr   bool
+	}{
+		{"first attempt", "2025.01.04. 오후 3:25", 0, "GPT " + normalized, 1, false},
+		{"retried", "2025.01.04. 오후 3:25", 2, "GPT " + normalized, 3, false},
+		{"local parser", "2025.01.04. 오후 3:25", 3, normalized, 3, false},
+		{"nothing parses", "어제 오후", 3, "", 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			newGPTServer(t, func(request GPTRequest) (string, error) {
+				calls++
+				if request.Content != tt.raw || request.Prompt != datePrompt {
+					t.Errorf("GPT request = %+v, want the raw date and datePrompt", request)
+				}
+				if calls <= tt.failures {
+					return "", errors.New("unavailable")
+				}
+				return "GPT " + normalized, nil
+			})
+
+			got, err := cleanDate(context.Background(), NewsArticle{Date: tt.raw})
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("cleanDate = (%q, %v), want %q", got, err, tt.want)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("GPT calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCleanDateStopsRetryingWhenContextEnds(t *testing.T) {
+	dateBackoff = time.Hour
+	t.Cleanup(func() { dateBackoff = time.Second })
+	t.Setenv("DATE_GPT_ATTEMPTS", "2")
+	calls := 0
+	newGPTServer(t, func(GPTRequest) (string, error) {
+		calls++
+		return "", errors.New("unavailable")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	got, err := cleanDate(ctx, NewsArticle{Date: "2025.01.04. 오후 3:25"})
+	if err != nil || got != "2025년 01월 04일 오후 3시 25분" {
+		t.Errorf("cleanDate = (%q, %v), want the locally parsed date", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cleanDate took %v, want it to stop waiting when the context ends", elapsed)
+	}
+	if calls != 1 {
+		t.Errorf("GPT calls = %d, want no retry after the context ended", calls)
+	}
+}
+
+func TestCleanDatePreparse(t *testing.T) {
+	t.Setenv("DATE_PREPARSE", "true")
+	t.Setenv("DATE_GPT_ATTEMPTS", "1")
+	const doubled = "입력 2025.01.04. 오후 3:25 수정 2025.01.04. 오후 4:08"
+
+	tests := []struct {
+		name          string
+		raw           string
+		maxTimestamps string
+		want          string
+		wantGPT       bool
+	}{
+		{"clear-cut", "2025.01.04. 오후 3:25", "", "2025년 01월 04일 오후 3시 25분", false},
+		{"same timestamp twice", "2025-01-04 15:25:00 2025.01.04. 오후 3:25", "", "2025년 01월 04일 오후 3시 25분", false},
+		{"doubled timestamp", doubled, "", "GPT", true},
+		{"doubled timestamp within DATE_MAX_TIMESTAMPS", doubled, "2", "2025년 01월 04일 오후 3시 25분", false},
+		{"invalid DATE_MAX_TIMESTAMPS", doubled, "many", "GPT", true},
+		{"unrecognized format", "Jan 4, 2025 3:25 PM", "", "GPT", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATE_MAX_TIMESTAMPS", tt.maxTimestamps)
+			called := false
+			newGPTServer(t, func(GPTRequest) (string, error) {
+				called = true
+				return "GPT", nil
+			})
+
+			got, err := cleanDate(context.Background(), NewsArticle{Date: tt.raw})
+			if err != nil || got != tt.want {
+				t.Errorf("cleanDate(%q) = (%q, %v), want %q", tt.raw, got, err, tt.want)
+			}
+			if called != tt.wantGPT {
+				t.Errorf("GPT called = %v, want %v", called, tt.wantGPT)
+			}
+		})
+	}
+}
+
+func TestCleanContentChainsPrompts(t *testing.T) {
+	t.Setenv("PROMPT_CONTENT_STEPS", "")
+	t.Setenv("PROMPT_CONTENT_1", "step1")
+	t.Setenv("PROMPT_CONTENT_2", "step2")
+	t.Setenv("PROMPT_CONTENT_3", "step3")
+
+	tests := []struct {
+		name     string
+		failing  string
+		want     string
+		wantErr  bool
+		wantSeen []string
+	}{
+		{"every prompt", "", "원문 step1 step2 step3", false, []string{"원문", "원문 step1", "원문 step1 step2"}},
+		{"middle prompt fails", "step2", "원문 step1", false, []string{"원문", "원문 step1"}},
+		{"last prompt fails", "step3", "원문 step1 step2", false, []string{"원문", "원문 step1", "원문 step1 step2"}},
+		{"first prompt fails", "step1", "", true, []string{"원문"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 각 단계는 받은 내용 뒤에 자기 이름을 붙여 돌려줌
+			var seen []string
+			newGPTServer(t, func(request GPTRequest) (string, error) {
+				seen = append(seen, request.Content)
+				if request.Prompt == tt.failing {
+					return "", errors.New("unavailable")
+				}
+				return request.Content + " " + request.Prompt, nil
+			})
+
+			got, err := cleanContent(context.Background(), NewsArticle{Content: "원문"})
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("cleanContent = (%q, %v), want %q", got, err, tt.want)
+			}
+			if !slices.Equal(seen, tt.wantSeen) {
+				t.Errorf("prompt inputs = %q, want %q", seen, tt.wantSeen)
+			}
+		})
+	}
+}
+
+// convertSingle sends article to Handler in single-article mode.
+func convertSingle(t *testing.T, article NewsArticle) events.APIGatewayProxyResponse {
+	t.Helper()
+	body, err := json.Marshal(article)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestHandlerKeepsOriginalContentWhenGPTFails(t *testing.T) {
+	t.Setenv("PROMPT_CONTENT_1", "정리해주세요")
+	t.Setenv("DATE_GPT_ATTEMPTS", "1")
+	original := "정부 예산안이 오늘 국회를 통과했다."
+
+	tests := []struct {
+		name  string
+		reply func(GPTRequest) (string, error)
+	}{
+		{"server error", func(GPTRequest) (string, error) { return "", errors.New("unavailable") }},
+		{"blank output", func(GPTRequest) (string, error) { return "  \n", nil }},
+		// 정제 결과가 sanitize 후 비게 되는 경우
+		{"output sanitized away", func(GPTRequest) (string, error) { return "<script>alert(1)</script>", nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SANITIZE_HTML", "true")
+			newGPTServer(t, tt.reply)
+
+			response := convertSingle(t, NewsArticle{Title: "예산안", Content: original, Date: "2025.01.04. 오후 3:25"})
+			if response.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d %s, want 200", response.StatusCode, response.Body)
+			}
+			if !strings.Contains(response.Body, "내용: "+original) {
+				t.Errorf("document =\n%s\nwant the original content", response.Body)
+			}
+		})
+	}
+}
+
+func TestHandlerRendersSanitizedHTMLOnce(t *testing.T) {
+	newGPTServer(t, func(GPTRequest) (string, error) { return "", errors.New("unavailable") })
+	t.Setenv("DATE_GPT_ATTEMPTS", "1")
+	t.Setenv("SANITIZE_HTML", "true")
+	t.Setenv("ALLOWED_HTML_TAGS", "b")
+	body, err := json.Marshal(NewsArticle{
+		Title:   "<b>예산안</b> & 국회",
+		Content: "<b>통과</b><script>\nalert(1)\n\n</script> 1 < 2\n\n<i>끝</i>",
+		Date:    "2025.01.04. 오후 3:25",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		Body:                  string(body),
+		QueryStringParameters: map[string]string{"format": "html"},
+	})
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Handler = (%d %s, %v), want 200", response.StatusCode, response.Body, err)
+	}
+	for _, want := range []string{
+		"<h1>제목: <b>예산안</b> &amp; 국회</h1>",
+		"<p><b>통과</b> 1 &lt; 2</p>",
+		"<p>&lt;i&gt;끝&lt;/i&gt;</p>",
+	} {
+		if !strings.Contains(response.Body, want) {
+			t.Errorf("document =\n%s\nwant %s", response.Body, want)
+		}
+	}
+	if strings.Contains(response.Body, "&amp;lt;") || strings.Contains(response.Body, "alert") {
+		t.Errorf("document =\n%s\nwant the text escaped once and the script removed", response.Body)
+	}
+
+	// markdown 출력은 기존처럼 꺾쇠만 이스케이프
+	response, _ = Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if want := "<b>통과</b> 1 &lt; 2"; !strings.Contains(response.Body, want) {
+		t.Errorf("markdown document =\n%s\nwant %s", response.Body, want)
+	}
+}
+
+func TestHandlerRejectsEmptyContent(t *testing.T) {
+	newGPTServer(t, func(GPTRequest) (string, error) { return "", errors.New("unavailable") })
+	t.Setenv("PROMPT_CONTENT_1", "정리해주세요")
+	t.Setenv("DATE_GPT_ATTEMPTS", "1")
+
+	response := convertSingle(t, NewsArticle{Title: "빈 기사", Content: " \n ", Date: "2025.01.04. 오후 3:25"})
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d %s, want 422 rather than an empty document", response.StatusCode, response.Body)
+	}
+}
+
+func TestRestoreEmptyContent(t *testing.T) {
+	article := NewsArticle{Content: "정제된 본문"}
+	if !restoreEmptyContent(&article, "원문") || article.Content != "정제된 본문" {
+		t.Errorf("restoreEmptyContent replaced non-empty content: %q", article.Content)
+	}
+	article.Content = " \n"
+	if !restoreEmptyContent(&article, "원문") || article.Content != "원문" {
+		t.Errorf("restoreEmptyContent = %q, want the original", article.Content)
+	}
+	article.Content = ""
+	if restoreEmptyContent(&article, "  ") {
+		t.Error("restoreEmptyContent succeeded with an empty original")
+	}
+}
+
+func TestFetchGPTRejectsErrorPages(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{"cleaned text", "text/plain; charset=utf-8", "정리된 본문입니다.", ""},
+		{"JSON-looking content", "text/plain", `{"title": "기사"}`, ""},
+		{"HTML error page", "text/html", "<html><body><h1>502 Bad Gateway</h1></body></html>", "HTML page"},
+		{"HTML without content type", "text/plain", "<!DOCTYPE html>\n<html><body>Internal Server Error</body></html>", "HTML page"},
+		{"gpt-api error body", "application/json", `{"error": "Failed to gpt connection"}`, "Failed to gpt connection"},
+		{"API Gateway error body", "application/json", `{"message": "Internal server error"}`, "Internal server error"},
+		{"blank body", "text/plain", " \n", "empty response"},
+		{"non-UTF-8 body", "text/plain", "\xb1\xe2\xbb\xe7", "non-UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			t.Setenv("GPT_SERVER", server.URL)
+			t.Setenv("GPT_RESPONSE_CHECK", "")
+
+			got, err := FetchGPT(context.Background(), GPTRequest{Content: "본문", Prompt: "정리해주세요"})
+			if tt.wantErr == "" {
+				if err != nil || got != tt.body {
+					t.Errorf("FetchGPT = (%q, %v), want %q", got, err, tt.body)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("FetchGPT = (%q, %v), want an error mentioning %q", got, err, tt.wantErr)
+			}
+
+			// GPT_RESPONSE_CHECK=false 는 검증 없이 그대로 반환
+			t.Setenv("GPT_RESPONSE_CHECK", "false")
+			if got, err := FetchGPT(context.Background(), GPTRequest{Content: "본문"}); err != nil || got != tt.body {
+				t.Errorf("FetchGPT with GPT_RESPONSE_CHECK=false = (%q, %v), want the body unchanged", got, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"top heading becomes ##", "# 개요\n\n### 세부", "## 개요\n\n#### 세부"},
+		{"deeper headings shift up", "### 개요\n#### 세부", "## 개요\n### 세부"},
+		{"hashtag is not a heading", "#해시태그 본문", "#해시태그 본문"},
+		{"bullets", "* 하나\n+ 둘\n• 셋\n  * 하위", "- 하나\n- 둘\n- 셋\n  - 하위"},
+		{"ordered lists renumbered", "1) 가\n5. 나\n\n9. 다\n문단\n1. 라\n3. 마", "1. 가\n2. 나\n\n3. 다\n문단\n1. 라\n2. 마"},
+		{"date-leading lines are prose", "2025. 1. 4. 오후 3시 기준\n12. 3. 발표\n\n2025.\n본문", "2025. 1. 4. 오후 3시 기준\n12. 3. 발표\n\n2025.\n본문"},
+		{"list must start at 1", "3. 서울\n4. 부산", "3. 서울\n4. 부산"},
+		{"empty markers, trailing spaces and blank lines", "첫 줄   \n-\n\n\n\n1.\n둘째 줄\t", "첫 줄\n\n둘째 줄"},
+		{"CRLF", "첫 줄\r\n\r\n\r\n둘째 줄\r\n", "첫 줄\n\n둘째 줄"},
+		{"already normalized", "## 개요\n\n- 하나\n\n1. 가\n2. 나", "## 개요\n\n- 하나\n\n1. 가\n2. 나"},
+	}
+	for _, tt := range tests {
+		if got := normalizeMarkdown(tt.in); got != tt.want {
+			t.Errorf("%s: normalizeMarkdown(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeContentToggle(t *testing.T) {
+	content := "* 하나\n\n\n\n* 둘"
+	article := NewsArticle{Content: content}
+	t.Setenv("NORMALIZE_MARKDOWN", "")
+	normalizeContent(&article)
+	if article.Content != content {
+		t.Errorf("content without NORMALIZE_MARKDOWN = %q, want it unchanged", article.Content)
+	}
+	t.Setenv("NORMALIZE_MARKDOWN", "true")
+	normalizeContent(&article)
+	if want := "- 하나\n\n- 둘"; article.Content != want {
+		t.Errorf("content with NORMALIZE_MARKDOWN=true = %q, want %q", article.Content, want)
+	}
+}
@@ -1,4 +1,4 @@
-module main
+module github.com/Sniij/mircro-services-golang/crawling
 
 go 1.23
 
@@ -7,8 +7,12 @@ toolchain go1.23.4
 require github.com/PuerkitoBio/goquery v1.10.1
 
 require (
+	github.com/Sniij/mircro-services-golang/model v0.0.0
 	github.com/andybalholm/cascadia v1.3.3 // indirect
 	github.com/aws/aws-lambda-go v1.47.0
 	github.com/joho/godotenv v1.5.1
-	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0 // indirect
 )
+
+replace github.com/Sniij/mircro-services-golang/model => ../model
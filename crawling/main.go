@@ -1,29 +1,49 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/Sniij/mircro-services-golang/model"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/joho/godotenv"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
 )
 
-// NewsArticle represents a news article with title and content.
-type NewsArticle struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	Date    string `json:"date"`
-}
+// NewsArticle is the article exchanged between the services.
+type NewsArticle = model.NewsArticle
+
+// RelatedArticle is a related-story link listed on an article page.
+type RelatedArticle = model.RelatedArticle
+
+// httpClient is shared by every fetch of an invocation so connections are reused
+var httpClient = &http.Client{}
 
 var BASE_URL string
 var BASE_URL_DETAIL string
@@ -44,24 +64,194 @@ func init() {
 	if err != nil {
 		log.Printf("failed to get server url: %v", err)
 	}
+	naverSite.BaseURL, naverSite.ArticleLink = BASE_URL, BASE_URL_DETAIL
+	transport := model.NewTransport()
+	if proxy := os.Getenv("SCRAPE_PROXY_URL"); proxy != "" {
+		if err := setProxy(transport, proxy); err != nil {
+			log.Printf("Ignoring SCRAPE_PROXY_URL: %v", err)
+		}
+	}
+	httpClient.Transport = transport
+}
+
+// setProxy routes the transport through an http, https or socks5 proxy URL.
+func setProxy(transport *http.Transport, proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme: %q", u.Scheme)
+	}
+	transport.Proxy = http.ProxyURL(u)
+	return nil
+}
+
+// NotHTMLError is returned by FetchHTML when the response is not an HTML document.
+type NotHTMLError struct {
+	URL         string
+	ContentType string
+}
+
+func (e *NotHTMLError) Error() string {
+	return fmt.Sprintf("not an HTML document (Content-Type: %s): %s", e.ContentType, e.URL)
+}
+
+// isHTMLContentType reports whether contentType is HTML. A missing header is
+// accepted since the document type cannot be told without parsing.
+func isHTMLContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// StatusError is returned by FetchHTML when the server answers with a non-200 status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// isRetryable reports whether a fetch error is transient: connection errors and
+// 429/5xx responses. Other statuses, non-HTML responses and context errors fail fast.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var notHTML *NotHTMLError
+	if errors.As(err, &notHTML) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode == http.StatusTooManyRequests || status.StatusCode >= 500
+	}
+	var fetchErr *fetchError
+	return errors.As(err, &fetchErr)
+}
+
+// fetchError wraps a transport-level failure, such as a dropped connection.
+type fetchError struct {
+	err error
+}
+
+func (e *fetchError) Error() string {
+	return fmt.Sprintf("failed to fetch URL: %v", e.err)
+}
+
+func (e *fetchError) Unwrap() error {
+	return e.err
+}
+
+// Fetcher loads and parses a page, so scraping can run against local fixtures
+// instead of the network.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (*goquery.Document, error)
+}
+
+// HTTPFetcher is the default Fetcher, fetching over the network with FetchHTML.
+type HTTPFetcher struct{}
+
+func (HTTPFetcher) Fetch(ctx context.Context, url string) (*goquery.Document, error) {
+	return FetchHTML(ctx, url)
+}
+
+// FetchHTML fetches the HTML document from a given URL, retrying transient failures
+// up to FETCH_MAX_RETRIES times (default 3) with exponential backoff and jitter.
+func FetchHTML(ctx context.Context, url string) (*goquery.Document, error) {
+	return fetchWithRetry(ctx, url, getEnvInt("FETCH_MAX_RETRIES", 3), 500*time.Millisecond)
+}
+
+// FetchHTMLWithRetry fetches like FetchHTML but with its own number of attempts and
+// initial backoff, for requests that deserve more patience than a single article.
+func FetchHTMLWithRetry(ctx context.Context, url string, attempts int, backoff time.Duration) (*goquery.Document, error) {
+	return fetchWithRetry(ctx, url, attempts-1, backoff)
+}
+
+// fetchWithRetry calls fetchHTMLOnce up to retries+1 times. The wait starts at backoff,
+// doubles each attempt and adds up to 50% jitter. It gives up early when the wait
+// would run past the context deadline.
+func fetchWithRetry(ctx context.Context, url string, retries int, backoff time.Duration) (*goquery.Document, error) {
+	if retries < 0 {
+		retries = 0
+	}
+	// robots.txt 는 실제로 보낼 User-Agent 기준으로 확인하므로 재시도에도 같은 값을 씀
+	agent := pickUserAgent()
+	if os.Getenv("RESPECT_ROBOTS") != "false" && !robotsAllowed(ctx, url, agent) {
+		return nil, &RobotsDisallowedError{URL: url}
+	}
+
+	attempts := retries + 1
+	for attempt := 1; ; attempt++ {
+		doc, err := fetchHTMLOnce(ctx, url, agent)
+		if err == nil {
+			return doc, nil
+		}
+		if attempt == attempts || !isRetryable(err) {
+			if attempt > 1 {
+				log.Printf("Fetch of %s failed after %d attempts: %v", url, attempt, err)
+			}
+			return nil, err
+		}
+
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			log.Printf("Fetch of %s failed after %d attempts, no time left to retry: %v", url, attempt, err)
+			return nil, err
+		}
+		log.Printf("Fetch attempt %d/%d for %s failed: %v. Retrying in %s", attempt, attempts, url, err, wait)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
 }
 
-// FetchHTML fetches the HTML document from a given URL.
-func FetchHTML(url string) (*goquery.Document, error) {
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; v1.0)")
+// fetchHTMLOnce performs a single fetch of url sent with the User-Agent agent.
+func fetchHTMLOnce(ctx context.Context, url, agent string) (*goquery.Document, error) {
+	defer model.LogTiming("FetchHTML", url, time.Now())
 
-	res, err := http.DefaultClient.Do(req)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch URL: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", agent)
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &fetchError{err: err}
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+		return nil, &StatusError{URL: url, StatusCode: res.StatusCode}
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	// PDF, JSON, 이미지 등 기사가 아닌 리소스는 파싱 전에 걸러냄
+	if contentType := res.Header.Get("Content-Type"); !isHTMLContentType(contentType) {
+		return nil, &NotHTMLError{URL: url, ContentType: contentType}
+	}
+
+	body, err := decodeBody(res)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %v", err)
 	}
@@ -69,13 +259,386 @@ func FetchHTML(url string) (*goquery.Document, error) {
 	return doc, nil
 }
 
-// ScrapeHeadlines extracts the top 5 headline links from the section page.
+// decodeBody returns the response body as UTF-8. gzip and deflate bodies the transport
+// did not decompress itself are inflated, and non-UTF-8 bodies (e.g. EUC-KR) are
+// transcoded using the Content-Type charset, the meta charset tag or, when neither is
+// declared, detection.
+func decodeBody(res *http.Response) (io.Reader, error) {
+	var body io.Reader = res.Body
+	if !res.Uncompressed {
+		switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+		case "gzip", "x-gzip":
+			reader, err := gzip.NewReader(res.Body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress gzip body: %v", err)
+			}
+			body = reader
+		case "deflate":
+			// deflate 는 보통 zlib 헤더가 붙지만 헤더 없는 raw deflate 를 보내는 서버도 있음
+			buffered := bufio.NewReader(res.Body)
+			if header, err := buffered.Peek(2); err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+				reader, err := zlib.NewReader(buffered)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decompress deflate body: %v", err)
+				}
+				body = reader
+			} else {
+				body = flate.NewReader(buffered)
+			}
+		}
+	}
+
+	reader, err := charset.NewReader(body, res.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode charset: %v", err)
+	}
+	return reader, nil
+}
+
+// userAgent identifies the crawler when it downloads robots.txt.
+const userAgent = "Mozilla/5.0 (compatible; v1.0)"
+
+// defaultUserAgents is the pool FetchHTML picks from when USER_AGENTS is unset.
+var defaultUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.2 Safari/605.1.15",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0",
+}
+
+// userAgentIntn picks the pool index. Tests may replace it for a deterministic choice.
+var userAgentIntn = rand.Intn
+
+// userAgents returns the pool from USER_AGENTS or the default list. Entries are
+// separated by commas not followed by a space, so "KHTML, like Gecko" stays intact.
+func userAgents() []string {
+	value := os.Getenv("USER_AGENTS")
+	if value == "" {
+		return defaultUserAgents
+	}
+	var pool []string
+	for _, part := range strings.Split(value, ",") {
+		// 공백으로 시작하면 앞 항목의 일부 (예: "KHTML, like Gecko")
+		if len(pool) > 0 && strings.HasPrefix(part, " ") {
+			pool[len(pool)-1] += "," + part
+			continue
+		}
+		pool = append(pool, part)
+	}
+	var agents []string
+	for _, agent := range pool {
+		if agent = strings.TrimSpace(agent); agent != "" {
+			agents = append(agents, agent)
+		}
+	}
+	if len(agents) == 0 {
+		return defaultUserAgents
+	}
+	return agents
+}
+
+// pickUserAgent returns a random User-Agent of the pool for one fetch.
+func pickUserAgent() string {
+	agents := userAgents()
+	return agents[userAgentIntn(len(agents))]
+}
+
+// RobotsDisallowedError is returned by FetchHTML when robots.txt disallows the URL.
+type RobotsDisallowedError struct {
+	URL string
+}
+
+func (e *RobotsDisallowedError) Error() string {
+	return fmt.Sprintf("disallowed by robots.txt: %s", e.URL)
+}
+
+// robotsRule is an Allow or Disallow line of the robots.txt group matching an agent.
+type robotsRule struct {
+	pattern *regexp.Regexp
+	length  int
+	allow   bool
+}
+
+// robotsEntry caches the robots.txt of one host, fetched once even under concurrent
+// scrapes, and its rules parsed for each User-Agent sent to the host.
+type robotsEntry struct {
+	once  sync.Once
+	body  string
+	rules map[string][]robotsRule
+}
+
+var (
+	robotsMu    sync.Mutex
+	robotsCache = make(map[string]*robotsEntry)
+)
+
+// resetRobotsCache drops the cached robots.txt rules so each invocation reads them fresh.
+func resetRobotsCache() {
+	robotsMu.Lock()
+	robotsCache = make(map[string]*robotsEntry)
+	robotsMu.Unlock()
+}
+
+// robotsAllowed reports whether the robots.txt of the URL host allows agent to fetch
+// it. The longest matching rule wins and Allow wins ties. A robots.txt that cannot be
+// read allows everything.
+func robotsAllowed(ctx context.Context, rawURL, agent string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return true
+	}
+
+	robotsMu.Lock()
+	entry, ok := robotsCache[u.Host]
+	if !ok {
+		entry = &robotsEntry{rules: make(map[string][]robotsRule)}
+		robotsCache[u.Host] = entry
+	}
+	robotsMu.Unlock()
+	entry.once.Do(func() {
+		entry.body = fetchRobots(ctx, u.Scheme+"://"+u.Host+"/robots.txt")
+	})
+
+	robotsMu.Lock()
+	rules, ok := entry.rules[agent]
+	if !ok {
+		rules = parseRobots(entry.body, agent)
+		entry.rules[agent] = rules
+	}
+	robotsMu.Unlock()
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	allowed, longest := true, -1
+	for _, rule := range rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || (rule.length == longest && rule.allow) {
+			allowed, longest = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
+
+// fetchRobots downloads a robots.txt. Missing or unreadable files yield an empty body,
+// which has no rules.
+func fetchRobots(ctx context.Context, robotsURL string) string {
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		log.Printf("failed to create robots.txt request: %v", err)
+		return ""
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to fetch %s, allowing all paths: %v", robotsURL, err)
+		return ""
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		if res.StatusCode >= 500 {
+			log.Printf("Failed to fetch %s (status %d), allowing all paths", robotsURL, res.StatusCode)
+		}
+		return ""
+	}
+	// 비정상적으로 큰 파일은 앞부분만 읽음
+	body, err := io.ReadAll(io.LimitReader(res.Body, 512*1024))
+	if err != nil {
+		log.Printf("Failed to read %s, allowing all paths: %v", robotsURL, err)
+		return ""
+	}
+	return string(body)
+}
+
+// parseRobots returns the rules of the group naming agent, or of the "*" group when
+// no group names it. A group name matches when agent contains it, case-insensitively.
+func parseRobots(body, agent string) []robotsRule {
+	agent = strings.ToLower(agent)
+
+	var specific, wildcard []robotsRule
+	var hasSpecific bool
+	// 현재 그룹이 agent 또는 * 에 해당하는지
+	var groupSpecific, groupWildcard, inRules bool
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// 규칙 뒤에 오는 User-agent 는 새 그룹의 시작
+			if inRules {
+				groupSpecific, groupWildcard, inRules = false, false, false
+			}
+			name := strings.ToLower(value)
+			if name == "*" {
+				groupWildcard = true
+			} else if name != "" && strings.Contains(agent, name) {
+				groupSpecific, hasSpecific = true, true
+			}
+		case "allow", "disallow":
+			inRules = true
+			// 빈 Disallow 는 모두 허용이므로 규칙이 필요 없음
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{pattern: robotsPattern(value), length: len(value), allow: key == "allow"}
+			if groupSpecific {
+				specific = append(specific, rule)
+			}
+			if groupWildcard {
+				wildcard = append(wildcard, rule)
+			}
+		}
+	}
+	if hasSpecific {
+		return specific
+	}
+	return wildcard
+}
+
+// robotsPattern compiles a robots.txt path pattern, supporting the * and trailing $ wildcards.
+func robotsPattern(value string) *regexp.Regexp {
+	anchored := strings.HasSuffix(value, "$")
+	value = strings.TrimSuffix(value, "$")
+	pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(value), `\*`, ".*")
+	if anchored {
+		pattern += "$"
+	}
+	return regexp.MustCompile(pattern)
+}
+
+// SiteConfig holds the extraction selectors of one news provider. Fetching is
+// shared; only the selectors and link rules vary per site.
+type SiteConfig struct {
+	Name string
+	// Lists maps the ?mode= values of the handler to the link selector of the
+	// section page list to scrape. "headline" is the default.
+	Lists   map[string]string
+	Title   string
+	Content string
+	// Strip is removed from the content element before its text is read
+	Strip string
+	// Date is the selector of the displayed timestamp, the "text" date source
+	Date string
+	// DateAttr is the attribute holding the machine-readable timestamp on the Date
+	// element (or elsewhere), the "attr" date source. Optional
+	DateAttr string
+	// Author is the byline selector, optional
+	Author string
+	// Related is the selector of the related-article links, optional
+	Related string
+	// BaseURL prefixes relative links; article links must contain ArticleLink
+	BaseURL     string
+	ArticleLink string
+}
+
+// naverSite is the default config, also used for unknown hosts.
+// BaseURL and ArticleLink come from BASE_URL and BASE_URL_DETAIL in init.
+var naverSite = &SiteConfig{
+	Name: "naver",
+	Lists: map[string]string{
+		"headline": "ul.sa_list li a",
+		"ranking":  "ul.as_ranking_list li a, div.section_component.as_section_ranking li a",
+	},
+	Title:    ".media_end_head_headline",
+	Content:  "#dic_area",
+	Strip:    "#dic_area span",
+	Date:     ".media_end_head_info_datestamp_time",
+	DateAttr: "data-date-time",
+	Author:   ".media_end_head_journalist_name",
+	Related:  ".media_end_linked_more_item a, .ofhd_float_related a, ._related_news a",
+}
+
+var daumSite = &SiteConfig{
+	Name: "daum",
+	Lists: map[string]string{
+		"headline": "ul.list_newsheadline2 li a, ul.list_news2 strong.tit_g a",
+		"ranking":  "ul.list_news2 li .tit_g a",
+	},
+	Title:       "h3.tit_view",
+	Content:     "div.article_view section",
+	Strip:       "div.article_view figure",
+	Date:        ".info_view .num_date",
+	Author:      ".info_view .txt_info:first-child",
+	Related:     ".box_relate .link_txt",
+	BaseURL:     "https://news.daum.net",
+	ArticleLink: "v.daum.net/v/",
+}
+
+// siteConfigs is the registry of supported providers keyed by hostname.
+var siteConfigs = map[string]*SiteConfig{
+	"news.naver.com":   naverSite,
+	"n.news.naver.com": naverSite,
+	"m.news.naver.com": naverSite,
+	"news.daum.net":    daumSite,
+	"v.daum.net":       daumSite,
+}
+
+// siteFor returns the config registered for the host of rawURL, falling back to Naver.
+func siteFor(rawURL string) *SiteConfig {
+	u, err := url.Parse(rawURL)
+	if err == nil {
+		if site, ok := siteConfigs[strings.ToLower(u.Hostname())]; ok {
+			return site
+		}
+	}
+	log.Printf("No site config for %s. Falling back to %s", rawURL, naverSite.Name)
+	return naverSite
+}
+
+// getEnvInt reads an integer env var, returning fallback when unset or invalid.
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q. Falling back to %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// ScrapeHeadlines extracts the top headline links from the section page.
 func ScrapeHeadlines(doc *goquery.Document) ([]string, error) {
+	return ScrapeList(doc, naverSite, "headline")
+}
+
+// ScrapeList extracts the top links of the site list selected by mode from the section
+// page, at most HEADLINE_LIMIT of them (default 5).
+func ScrapeList(doc *goquery.Document, site *SiteConfig, mode string) ([]string, error) {
+	selector, ok := site.Lists[mode]
+	if !ok {
+		return nil, fmt.Errorf("unsupported mode: %s", mode)
+	}
+
+	limit := getEnvInt("HEADLINE_LIMIT", 5)
+	if limit == 0 {
+		limit = 5
+	}
+
 	var links []string
 	seen := make(map[string]bool) // 중복 제거를 위한 map
 
-	doc.Find("ul.sa_list li a").EachWithBreak(func(i int, s *goquery.Selection) bool {
-		if len(links) >= 5 {
+	doc.Find(selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if len(links) >= limit {
 			return false
 		}
 
@@ -83,11 +646,11 @@ func ScrapeHeadlines(doc *goquery.Document) ([]string, error) {
 		if exists {
 			// 상대 경로 처리
 			if link[0] == '/' {
-				link = BASE_URL + link
+				link = site.BaseURL + link
 			}
 
 			// 댓글 링크 필터링 및 중복 제거
-			if isValidNewsLink(link) && !seen[link] {
+			if isValidNewsLink(link, site) && !seen[link] {
 				links = append(links, link)
 				seen[link] = true // 중복 방지
 			}
@@ -96,56 +659,689 @@ func ScrapeHeadlines(doc *goquery.Document) ([]string, error) {
 	})
 
 	if len(links) == 0 {
-		return nil, fmt.Errorf("no headlines found")
+		return nil, fmt.Errorf("no %s links found", mode)
 	}
 
 	log.Println("Extracted links:", links)
 
 	return links, nil
 }
-func isValidNewsLink(link string) bool {
-	return (len(link) > 0 && strings.Contains(link, BASE_URL_DETAIL) && !strings.Contains(link, "/comment/"))
+func isValidNewsLink(link string, site *SiteConfig) bool {
+	return (len(link) > 0 && strings.Contains(link, site.ArticleLink) && !strings.Contains(link, "/comment/"))
 }
 
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && len(substr) > 0 && (len(s)-len(substr) >= 0 && s[len(s)-len(substr):] == substr)
 }
 
+// naverArticlePath matches Naver article URLs such as /mnews/article/<press>/<article>.
+var naverArticlePath = regexp.MustCompile(`/article/(\d+)/(\d+)`)
+
+// ArticleID derives a deterministic ID from an article URL. Naver articles use
+// "<press>_<article>" from the path (or the legacy oid/aid query parameters); other
+// URLs use the first 16 hex characters of the SHA-256 of the URL without query or fragment.
+func ArticleID(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		sum := sha256.Sum256([]byte(rawURL))
+		return hex.EncodeToString(sum[:])[:16]
+	}
+
+	if m := naverArticlePath.FindStringSubmatch(u.Path); m != nil {
+		return m[1] + "_" + m[2]
+	}
+	if oid, aid := u.Query().Get("oid"), u.Query().Get("aid"); oid != "" && aid != "" {
+		return oid + "_" + aid
+	}
+
+	canonical := strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
 // ScrapeArticle extracts the title and content of a news article.
-func ScrapeArticle(url string) (NewsArticle, error) {
-	doc, err := FetchHTML(url)
+func ScrapeArticle(ctx context.Context, fetcher Fetcher, url string) (NewsArticle, error) {
+	defer model.LogTiming("ScrapeArticle", url, time.Now())
+
+	doc, err := fetcher.Fetch(ctx, url)
 	if err != nil {
 		return NewsArticle{}, err
 	}
 
-	// Extract title
-	title := doc.Find(".media_end_head_headline").Text()
+	site := siteFor(url)
+	// 본문 정리 과정에서 사진 영역이 지워지므로 추출 전에 읽음
+	image := ArticleImage(doc, site, url)
+	title, content, date := extractArticle(doc, site)
 
-	// Remove all <span> tags within #dic_area
-	doc.Find("#dic_area span").Remove()
-
-	// Extract content after removing <span> tags
-	content := doc.Find("#dic_area").Text()
+	// 본문이 JS 로 렌더링되어 정적 HTML 에서 찾지 못한 경우 렌더링 서버의 HTML 로 재시도
+	if (title == "" || content == "" || date == "") && os.Getenv("RENDER_FALLBACK") == "true" && ctx.Err() == nil {
+		rendered, err := FetchRendered(ctx, url)
+		if err != nil {
+			log.Printf("Render fallback failed for %s: %v", url, err)
+		} else {
+			doc = rendered
+			if image == "" {
+				image = ArticleImage(doc, site, url)
+			}
+			title, content, date = extractArticle(doc, site)
+			log.Printf("Used render fallback for %s", url)
+		}
+	}
 
-	// Extract date
-	date := doc.Find(".media_end_head_info_datestamp_time").Text()
+	// 템플릿이 바뀌어 기본 선택자로 아무것도 찾지 못한 경우 일반적인 추출 방식으로 재시도
+	fallback := false
+	if title == "" && content == "" && date == "" && os.Getenv("READABILITY_FALLBACK") == "true" {
+		title, content, date = extractReadable(doc)
+		fallback = true
+		log.Printf("Used fallback extraction for %s", url)
+	}
 
 	if title == "" || content == "" || date == "" {
 		return NewsArticle{}, fmt.Errorf("failed to extract title, content, or date")
 	}
 
+	// 기자 정보는 없어도 기사 자체는 유효하므로 실패로 처리하지 않음
+	author := ArticleAuthor(doc, site)
+
+	var related []RelatedArticle
+	if os.Getenv("INCLUDE_RELATED") == "true" {
+		related = ScrapeRelated(doc, site)
+	}
+	var comments, reactions int
+	if os.Getenv("SCRAPE_ENGAGEMENT") == "true" {
+		comments, reactions = ScrapeEngagement(doc)
+	}
+
 	return NewsArticle{
-		Title:   strings.TrimSpace(title),
-		Content: strings.TrimSpace(content),
-		Date:    strings.TrimSpace(date),
+		Title:             strings.TrimSpace(title),
+		Content:           strings.TrimSpace(content),
+		Date:              strings.TrimSpace(date),
+		URL:               url,
+		Author:            author,
+		ImageURL:          image,
+		ID:                ArticleID(url),
+		FallbackExtracted: fallback,
+		Related:           related,
+		Comments:          comments,
+		Reactions:         reactions,
 	}, nil
 }
 
+// extractArticle extracts the title, content and date with the site selectors.
+func extractArticle(doc *goquery.Document, site *SiteConfig) (string, string, string) {
+	// Extract title
+	title := doc.Find(site.Title).Text()
+
+	// Remove the noise (e.g. the <span> tags within #dic_area)
+	if site.Strip != "" {
+		doc.Find(site.Strip).Remove()
+	}
+
+	// Extract content after removing the noise
+	content := doc.Find(site.Content).Text()
+
+	// Extract date
+	date := ArticleDate(doc, site)
+
+	return title, content, date
+}
+
+// ArticleAuthor returns the byline of the article, joining multiple journalists with
+// ", ". It is empty when the site has no byline selector or the page has no byline.
+func ArticleAuthor(doc *goquery.Document, site *SiteConfig) string {
+	if site.Author == "" {
+		return ""
+	}
+	var names []string
+	seen := make(map[string]bool)
+	doc.Find(site.Author).Each(func(i int, s *goquery.Selection) {
+		name := strings.TrimSpace(s.Text())
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	})
+	return strings.Join(names, ", ")
+}
+
+// ArticleImage returns the absolute URL of the lead image from the og:image meta tag,
+// or else the first image of the content. It is empty when neither exists.
+func ArticleImage(doc *goquery.Document, site *SiteConfig, pageURL string) string {
+	image, _ := doc.Find(`meta[property="og:image"]`).Attr("content")
+	if strings.TrimSpace(image) == "" {
+		img := doc.Find(site.Content + " img").First()
+		// 지연 로딩 이미지는 data-src 에 실제 주소가 있음
+		if src, ok := img.Attr("data-src"); ok && src != "" {
+			image = src
+		} else {
+			image, _ = img.Attr("src")
+		}
+	}
+	image = strings.TrimSpace(image)
+	if image == "" {
+		return ""
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return image
+	}
+	ref, err := url.Parse(image)
+	if err != nil {
+		return image
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// FetchRendered asks the headless-render service at RENDER_SERVER for the fully
+// rendered HTML of url. The service receives {"url": ...} and returns the HTML.
+// RENDER_TIMEOUT bounds the call (default 30s) within ctx.
+func FetchRendered(ctx context.Context, url string) (*goquery.Document, error) {
+	defer model.LogTiming("FetchRendered", url, time.Now())
+
+	server := os.Getenv("RENDER_SERVER")
+	if server == "" {
+		return nil, fmt.Errorf("RENDER_SERVER is not set")
+	}
+	timeout := 30 * time.Second
+	if value := os.Getenv("RENDER_TIMEOUT"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			timeout = d
+		} else {
+			log.Printf("Invalid RENDER_TIMEOUT %q. Falling back to 30s", value)
+		}
+	}
+
+	body, err := json.Marshal(map[string]string{"url": url})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal render request: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", server, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create render request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call render server: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("render server returned status code %d", res.StatusCode)
+	}
+	rendered, err := decodeBody(res)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rendered HTML: %v", err)
+	}
+	return doc, nil
+}
+
+// dateSources extract the article timestamp from the different templates,
+// keyed by the names accepted in DATE_SOURCES.
+var dateSources = map[string]func(doc *goquery.Document, site *SiteConfig) string{
+	// ISO 8601 값, 가장 신뢰할 수 있음
+	"meta": func(doc *goquery.Document, site *SiteConfig) string {
+		date, _ := doc.Find(`meta[property="article:published_time"]`).Attr("content")
+		return date
+	},
+	// 화면 표시 문자열 대신 들어 있는 기계용 값 (예: 2025-01-05 10:00:00)
+	"attr": func(doc *goquery.Document, site *SiteConfig) string {
+		if site.DateAttr == "" {
+			return ""
+		}
+		// 표시 요소의 값을 우선하고, 없으면 다른 요소의 값을 씀
+		date, ok := doc.Find(site.Date).First().Attr(site.DateAttr)
+		if !ok {
+			date, _ = doc.Find("[" + site.DateAttr + "]").First().Attr(site.DateAttr)
+		}
+		return date
+	},
+	"text": func(doc *goquery.Document, site *SiteConfig) string {
+		return doc.Find(site.Date).First().Text()
+	},
+}
+
+// ArticleDate returns the first non-empty timestamp from the sources listed in
+// DATE_SOURCES, a comma-separated order of "meta", "attr" and "text" (the default order).
+func ArticleDate(doc *goquery.Document, site *SiteConfig) string {
+	order := os.Getenv("DATE_SOURCES")
+	if order == "" {
+		order = "meta,attr,text"
+	}
+	for _, name := range strings.Split(order, ",") {
+		source, ok := dateSources[strings.TrimSpace(name)]
+		if !ok {
+			log.Printf("Unknown date source %q in DATE_SOURCES", name)
+			continue
+		}
+		if date := strings.TrimSpace(source(doc, site)); date != "" {
+			return date
+		}
+	}
+	return ""
+}
+
+// ScrapeRelated extracts the related-article links of an article page with the site
+// Related selector. Pages without a related section simply yield no links.
+func ScrapeRelated(doc *goquery.Document, site *SiteConfig) []RelatedArticle {
+	if site.Related == "" {
+		return nil
+	}
+	var related []RelatedArticle
+	seen := make(map[string]bool)
+	doc.Find(site.Related).Each(func(i int, s *goquery.Selection) {
+		link, exists := s.Attr("href")
+		title := strings.TrimSpace(s.Text())
+		if !exists || link == "" || title == "" {
+			return
+		}
+		if link[0] == '/' {
+			link = site.BaseURL + link
+		}
+		if seen[link] {
+			return
+		}
+		seen[link] = true
+		related = append(related, RelatedArticle{Title: title, URL: link})
+	})
+	return related
+}
+
+// Engagement widget selectors of the Naver article page.
+const (
+	commentCountSelector  = "#comment_count, .media_end_head_cmtcount_button .u_cbox_count, .u_cbox_count"
+	reactionCountSelector = ".media_end_head_info_variety_likeit .u_likeit_text._count, ._reactionModule .u_likeit_text._count"
+)
+
+// ScrapeEngagement returns the comment and reaction counts of an article page.
+// Missing widgets count as zero.
+func ScrapeEngagement(doc *goquery.Document) (int, int) {
+	comments := parseCount(doc.Find(commentCountSelector).First().Text())
+	reactions := parseCount(doc.Find(reactionCountSelector).First().Text())
+	return comments, reactions
+}
+
+// parseCount parses a displayed count such as "1,234" or "1.2만", returning 0 when
+// no number is present.
+func parseCount(text string) int {
+	text = strings.ReplaceAll(strings.TrimSpace(text), ",", "")
+	multiplier := 1.0
+	if strings.HasSuffix(text, "만") {
+		multiplier = 10000
+		text = strings.TrimSuffix(text, "만")
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int(n * multiplier)
+}
+
+// extractReadable is a generic extraction used when the site selectors find nothing.
+// The content is the <article> element when present, otherwise the element holding
+// the most paragraph text.
+func extractReadable(doc *goquery.Document) (string, string, string) {
+	title, _ := doc.Find(`meta[property="og:title"]`).Attr("content")
+	if title == "" {
+		title = doc.Find("h1").First().Text()
+	}
+	if title == "" {
+		title = doc.Find("title").First().Text()
+	}
+
+	content := doc.Find("article").First().Text()
+	if strings.TrimSpace(content) == "" {
+		var best *goquery.Selection
+		scores := make(map[*html.Node]int)
+		doc.Find("p").Each(func(i int, p *goquery.Selection) {
+			parent := p.Parent()
+			if parent.Length() == 0 {
+				return
+			}
+			node := parent.Nodes[0]
+			scores[node] += len(strings.TrimSpace(p.Text()))
+			if best == nil || scores[node] > scores[best.Nodes[0]] {
+				best = parent
+			}
+		})
+		if best != nil {
+			content = best.Text()
+		}
+	}
+
+	date, _ := doc.Find(`meta[property="article:published_time"]`).Attr("content")
+	if date == "" {
+		date, _ = doc.Find("time").First().Attr("datetime")
+	}
+	if date == "" {
+		date = doc.Find("time").First().Text()
+	}
+
+	return title, content, date
+}
+
+// keptQueryParams survive the "query" normalization rule because legacy Naver
+// article URLs identify the article by them.
+var keptQueryParams = []string{"oid", "aid"}
+
+// NormalizeLink applies the comma-separated rules to link: "query" drops query
+// parameters (except oid/aid), "fragment" drops the fragment, "slash" trims trailing
+// slashes and "host" lowercases the host. Empty rules mean all of them.
+func NormalizeLink(link string, rules string) string {
+	if rules == "" {
+		rules = "query,fragment,slash,host"
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		switch strings.TrimSpace(rule) {
+		case "query":
+			query := u.Query()
+			kept := url.Values{}
+			for _, name := range keptQueryParams {
+				if value := query.Get(name); value != "" {
+					kept.Set(name, value)
+				}
+			}
+			u.RawQuery = kept.Encode()
+		case "fragment":
+			u.Fragment = ""
+			u.RawFragment = ""
+		case "slash":
+			u.Path = strings.TrimRight(u.Path, "/")
+			u.RawPath = ""
+		case "host":
+			u.Host = strings.ToLower(u.Host)
+		default:
+			log.Printf("Unknown URL_NORMALIZE rule %q", rule)
+		}
+	}
+	return u.String()
+}
+
+// DedupLinks drops links that normalize to an already seen link, keeping the first
+// occurrence as it was scraped.
+func DedupLinks(links []string, rules string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, link := range links {
+		key := NormalizeLink(link, rules)
+		if seen[key] {
+			log.Printf("Skipping duplicate link %s", link)
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, link)
+	}
+	return unique
+}
+
+// ScrapeArticles scrapes links concurrently and returns the articles in link order.
+// Each goroutine writes only its own slot, so failed scrapes simply leave a gap.
+// At most CRAWL_CONCURRENCY articles (default 5) are fetched at once.
+func ScrapeArticles(ctx context.Context, fetcher Fetcher, links []string) []NewsArticle {
+	slots := make([]*NewsArticle, len(links))
+	var wg sync.WaitGroup
+
+	concurrency := getEnvInt("CRAWL_CONCURRENCY", 5)
+	if concurrency == 0 {
+		concurrency = 5
+	}
+	sem := make(chan struct{}, concurrency)
+
+	for i, link := range links {
+		// 마감이 지나면 남은 기사는 시작하지 않음
+		if ctx.Err() != nil {
+			log.Printf("Skipping %d remaining articles: %v", len(links)-i, ctx.Err())
+			break
+		}
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			// 동시에 가져오는 기사 수 제한, 기다리는 중 마감되면 포기
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				log.Printf("Abandoned article %s: %v", url, ctx.Err())
+				return
+			}
+			defer func() { <-sem }()
+
+			article, err := ScrapeArticle(ctx, fetcher, url)
+			if err != nil {
+				if ctx.Err() != nil {
+					log.Printf("Abandoned article %s: %v", url, ctx.Err())
+					return
+				}
+				var notHTML *NotHTMLError
+				if errors.As(err, &notHTML) {
+					log.Printf("Skipping non-article link: %v", err)
+					return
+				}
+				log.Printf("Error scraping article: %v", err)
+				return
+			}
+			slots[i] = &article
+		}(i, link)
+	}
+	wg.Wait()
+
+	var articles []NewsArticle
+	for _, article := range slots {
+		if article != nil {
+			articles = append(articles, *article)
+		}
+	}
+	return articles
+}
+
+// sentenceEnd splits a line after sentence-ending punctuation followed by a space.
+var sentenceEnd = regexp.MustCompile(`[.!?]\s+`)
+
+// blockSpans returns the [start, end) offsets in content of its lines and sentences,
+// the units compared across articles, without their surrounding whitespace.
+func blockSpans(content string) [][2]int {
+	var spans [][2]int
+	add := func(start, end int) {
+		block := content[start:end]
+		start += len(block) - len(strings.TrimLeftFunc(block, unicode.IsSpace))
+		if block = strings.TrimSpace(block); block != "" {
+			spans = append(spans, [2]int{start, start + len(block)})
+		}
+	}
+
+	lineStart := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		start := 0
+		for _, loc := range sentenceEnd.FindAllStringIndex(line, -1) {
+			add(lineStart+start, lineStart+loc[0]+1)
+			start = loc[1]
+		}
+		add(lineStart+start, lineStart+len(line))
+		lineStart += len(line)
+	}
+	return spans
+}
+
+// removeSpans cuts the spans out of content, keeping its other separators. A span is
+// removed with the spaces after it, a line left empty is removed with its line break,
+// and blank lines do not pile up where a paragraph was removed.
+func removeSpans(content string, spans [][2]int) string {
+	var b strings.Builder
+	next := 0
+	lineStart := 0
+	prevBlank, afterRemoved := true, false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		lineEnd := lineStart + len(line)
+		var kept strings.Builder
+		removed := false
+		pos := lineStart
+		for next < len(spans) && spans[next][0] < lineEnd {
+			kept.WriteString(content[pos:spans[next][0]])
+			pos = spans[next][1]
+			for pos < lineEnd && (content[pos] == ' ' || content[pos] == '\t') {
+				pos++
+			}
+			removed = true
+			next++
+		}
+		kept.WriteString(content[pos:lineEnd])
+		lineStart = lineEnd
+
+		text := kept.String()
+		blank := strings.TrimSpace(text) == ""
+		if removed {
+			if blank {
+				afterRemoved = true
+				continue
+			}
+			newline := strings.HasSuffix(text, "\n")
+			text = strings.TrimRight(text, " \t\n")
+			if newline {
+				text += "\n"
+			}
+		}
+		// 제거한 단락 앞뒤의 빈 줄이 겹치지 않도록 하나만 남김
+		if blank && prevBlank && afterRemoved {
+			continue
+		}
+		if !blank {
+			afterRemoved = false
+		}
+		b.WriteString(text)
+		prevBlank = blank
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// shingleSize is the number of consecutive words in a shingle.
+const shingleSize = 3
+
+// shingles returns the set of word shingles of text. Texts shorter than
+// shingleSize words have none and are never considered shared.
+func shingles(text string) map[string]bool {
+	words := strings.Fields(text)
+	set := make(map[string]bool)
+	for i := 0; i+shingleSize <= len(words); i++ {
+		set[strings.Join(words[i:i+shingleSize], " ")] = true
+	}
+	return set
+}
+
+// TrimSharedBoilerplate removes blocks (lines or sentences) of each article that also
+// appear in another article of the section, such as syndicated intros or standing
+// disclaimers, keeping the separators of the rest of the content. A block counts as
+// shared when at least overlap of its shingles occur in another article. An article
+// at least overlap shared is a duplicate rather than boilerplate: the first one of
+// such a group is kept whole and the later ones are dropped.
+func TrimSharedBoilerplate(articles []NewsArticle, overlap float64) []NewsArticle {
+	if len(articles) < 2 {
+		return articles
+	}
+
+	articleShingles := make([]map[string]bool, len(articles))
+	for i, article := range articles {
+		articleShingles[i] = shingles(article.Content)
+	}
+
+	kept := make(map[int]bool)
+	var result []NewsArticle
+	for i, article := range articles {
+		var sharedSpans [][2]int
+		total, shared, duplicated := 0, 0, 0
+		for _, span := range blockSpans(article.Content) {
+			block := article.Content[span[0]:span[1]]
+			total += len(block)
+			others := sharedWith(block, i, articleShingles, overlap)
+			if len(others) == 0 {
+				continue
+			}
+			shared += len(block)
+			sharedSpans = append(sharedSpans, span)
+			if slices.ContainsFunc(others, func(j int) bool { return kept[j] }) {
+				duplicated += len(block)
+			}
+		}
+
+		if total > 0 && float64(shared)/float64(total) >= overlap {
+			if float64(duplicated)/float64(total) >= overlap {
+				log.Printf("Skipping article mostly made of shared boilerplate: %s", article.URL)
+				continue
+			}
+			// 중복 그룹의 첫 기사는 잘라내지 않고 그대로 남김
+			kept[i] = true
+			result = append(result, article)
+			continue
+		}
+		if shared > 0 {
+			log.Printf("Trimmed %d of %d characters of shared boilerplate from %s", shared, total, article.URL)
+			article.Content = removeSpans(article.Content, sharedSpans)
+		}
+		kept[i] = true
+		result = append(result, article)
+	}
+	return result
+}
+
+// sharedWith returns the articles other than the one at self that block overlaps.
+func sharedWith(block string, self int, articleShingles []map[string]bool, overlap float64) []int {
+	blockShingles := shingles(block)
+	if len(blockShingles) == 0 {
+		return nil
+	}
+	var others []int
+	for j, other := range articleShingles {
+		if j == self {
+			continue
+		}
+		matched := 0
+		for shingle := range blockShingles {
+			if other[shingle] {
+				matched++
+			}
+		}
+		if float64(matched)/float64(len(blockShingles)) >= overlap {
+			others = append(others, j)
+		}
+	}
+	return others
+}
+
+// getEnvFloat reads a float env var, returning fallback when unset or invalid.
+func getEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		log.Printf("Invalid %s %q. Falling back to %v", key, value, fallback)
+		return fallback
+	}
+	return f
+}
+
 // Handler processes the Lambda event.
 func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// auto-push 가 보낸 상관관계 ID 를 이번 호출의 모든 로그에 붙여 서비스 간 추적
+	if id := request.Headers["x-correlation-id"]; id != "" {
+		log.SetPrefix("correlation_id=" + id + " ")
+		defer log.SetPrefix("")
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
 	defer cancel()
+	resetRobotsCache()
 
 	// Parse URL from query parameters
 	url := request.QueryStringParameters["url"]
@@ -155,57 +1351,62 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 			Body:       `{"error": "Missing 'url' parameter"}`,
 		}, nil
 	}
+
+	// 기본은 헤드라인 목록, ?mode=ranking 이면 많이 본 뉴스 목록
+	mode := request.QueryStringParameters["mode"]
+	if mode == "" {
+		mode = "headline"
+	}
+	site := siteFor(url)
+	if _, ok := site.Lists[mode]; !ok {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Unsupported mode: %s", mode)}),
+		}, nil
+	}
+
+	// GET 요청이므로 본문 대신 url 파라미터를 서명 대상으로 사용
+	if !model.VerifySignature(request.Headers, []byte(url), os.Getenv) {
+		log.Printf("Rejected request with invalid signature")
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusUnauthorized,
+			Body:       `{"error": "Invalid signature"}`,
+		}, nil
+	}
 	// Scrape the Headline
-	sectionDoc, err := FetchHTML(url)
+	// 섹션 페이지를 못 가져오면 섹션 전체가 비므로 기사보다 더 많이 재시도
+	sectionDoc, err := FetchHTMLWithRetry(ctx, url, getEnvInt("SECTION_FETCH_ATTEMPTS", 6), 2*time.Second)
 	if err != nil {
 		log.Printf("Error fetching section HTML: %v", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"error": "Error fetching section HTML: %v"}`, err),
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Error fetching section HTML: %v", err)}),
 		}, nil
 	}
 
 	// Scrape the headline links
-	headlineLinks, err := ScrapeHeadlines(sectionDoc)
+	headlineLinks, err := ScrapeList(sectionDoc, site, mode)
 	if err != nil {
 		log.Printf("Error scraping headlines: %v", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"error": "Error scraping headlines: %v"}`, err),
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Error scraping headlines: %v", err)}),
 		}, nil
 	}
 
-	var articles []NewsArticle
-	results := make(chan NewsArticle, len(headlineLinks))
-	var wg sync.WaitGroup
-
-	for _, link := range headlineLinks {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-			article, err := ScrapeArticle(url)
-			if err != nil {
-				log.Printf("Error scraping article: %v", err)
-				return
-			}
-			results <- article
-		}(link)
+	if os.Getenv("DEDUP_LINKS") == "true" {
+		headlineLinks = DedupLinks(headlineLinks, os.Getenv("URL_NORMALIZE"))
 	}
-
-	// Wait for all goroutines to complete
-	wg.Wait()
-	close(results)
-
-	// Collect results
-	for article := range results {
-		articles = append(articles, article)
+	articles := ScrapeArticles(ctx, HTTPFetcher{}, headlineLinks)
+	if os.Getenv("TRIM_SHARED_BOILERPLATE") == "true" {
+		articles = TrimSharedBoilerplate(articles, getEnvFloat("BOILERPLATE_OVERLAP", 0.8))
 	}
 
 	if len(articles) == 0 {
 		log.Println("No articles scraped")
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"error": "Error scraping article: %v"}`, err),
+			Body:       `{"error": "No articles scraped"}`,
 		}, nil
 	}
 
@@ -215,7 +1416,7 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		log.Printf("Error encoding JSON: %v", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
-			Body:       fmt.Sprintf(`{"error": "Failed to encoding JSON: %v"}`, err),
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to encoding JSON: %v", err)}),
 		}, nil
 	}
 
@@ -231,7 +1432,7 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 func HandlerTest(url string) {
 
 	// Scrape the Headline
-	sectionDoc, err := FetchHTML(url)
+	sectionDoc, err := FetchHTML(context.Background(), url)
 	if err != nil {
 		log.Printf("Error fetching section HTML: %v", err)
 	}
@@ -242,31 +1443,7 @@ func HandlerTest(url string) {
 		log.Printf("Error scraping headlines: %v", err)
 	}
 
-	var articles []NewsArticle
-	results := make(chan NewsArticle, len(headlineLinks))
-	var wg sync.WaitGroup
-
-	for _, link := range headlineLinks {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-			article, err := ScrapeArticle(url)
-			if err != nil {
-				log.Printf("Error scraping article: %v", err)
-				return
-			}
-			results <- article
-		}(link)
-	}
-
-	// Wait for all goroutines to complete
-	wg.Wait()
-	close(results)
-
-	// Collect results
-	for article := range results {
-		articles = append(articles, article)
-	}
+	articles := ScrapeArticles(context.Background(), HTTPFetcher{}, headlineLinks)
 
 	if len(articles) == 0 {
 		log.Println("No articles scraped")
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/Sniij/mircro-services-golang/model"
+)
+
+// fixtureFetcher serves the testdata HTML file mapped to each URL. Other URLs
+// fail with a 404 StatusError.
+type fixtureFetcher map[string]string
+
+func (f fixtureFetcher) Fetch(ctx context.Context, url string) (*goquery.Document, error) {
+	name, ok := f[url]
+	if !ok {
+		return nil, &StatusError{URL: url, StatusCode: 404}
+	}
+	return loadFixture(name)
+}
+
+// loadFixture parses testdata/name.
+func loadFixture(name string) (*goquery.Document, error) {
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return goquery.NewDocumentFromReader(file)
+}
+
+// useNaverURLs sets the Naver BASE_URL and BASE_URL_DETAIL values init reads from env.
+func useNaverURLs(t *testing.T) {
+	t.Helper()
+	base, link := naverSite.BaseURL, naverSite.ArticleLink
+	naverSite.BaseURL, naverSite.ArticleLink = "https://n.news.naver.com", "n.news.naver.com/mnews/article/"
+	t.Cleanup(func() { naverSite.BaseURL, naverSite.ArticleLink = base, link })
+}
+
+func TestTrimSharedBoilerplateKeepsSeparators(t *testing.T) {
+	disclaimer := "이 기사는 AI 요약 서비스 제공 대상이 아닙니다."
+	articles := []NewsArticle{
+		{URL: "a", Content: "정부가 새 예산안을 오늘 발표했다. " + disclaimer + "\n\n야당은 즉각 반발하며 재검토를 요구했다."},
+		{URL: "b", Content: "삼성전자가 신형 반도체를 오늘 공개했다.\n\n" + disclaimer},
+	}
+
+	got := TrimSharedBoilerplate(articles, 0.8)
+	want := []string{
+		"정부가 새 예산안을 오늘 발표했다.\n\n야당은 즉각 반발하며 재검토를 요구했다.",
+		"삼성전자가 신형 반도체를 오늘 공개했다.",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("kept %d articles, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Content != want[i] {
+			t.Errorf("article %s content = %q, want %q", got[i].URL, got[i].Content, want[i])
+		}
+	}
+}
+
+func TestTrimSharedBoilerplateKeepsFirstDuplicate(t *testing.T) {
+	content := "정부가 새 예산안을 오늘 발표했다.\n\n야당은 즉각 반발하며 재검토를 요구했다."
+	articles := []NewsArticle{
+		{URL: "first", Content: content},
+		{URL: "copy", Content: content},
+		{URL: "other", Content: "삼성전자가 신형 반도체를 오늘 공개했다."},
+	}
+
+	got := TrimSharedBoilerplate(articles, 0.8)
+	if len(got) != 2 || got[0].URL != "first" || got[1].URL != "other" {
+		t.Fatalf("kept %+v, want first and other", got)
+	}
+	if got[0].Content != content {
+		t.Errorf("first duplicate content = %q, want it untouched", got[0].Content)
+	}
+}
+
+func TestRemoveSpansCollapsesBlankLines(t *testing.T) {
+	content := "첫 단락\n\n지울 단락\n\n마지막 단락"
+	start := len("첫 단락\n\n")
+	got := removeSpans(content, [][2]int{{start, start + len("지울 단락")}})
+	if want := "첫 단락\n\n마지막 단락"; got != want {
+		t.Errorf("removeSpans = %q, want %q", got, want)
+	}
+}
+
+func TestScrapeList(t *testing.T) {
+	useNaverURLs(t)
+	tests := []struct {
+		name    string
+		fixture string
+		site    *SiteConfig
+		mode    string
+		limit   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "naver headline", fixture: "naver_section.html", site: naverSite, mode: "headline",
+			want: []string{
+				"https://n.news.naver.com/mnews/article/001/0015000001",
+				"https://n.news.naver.com/mnews/article/023/0003800002",
+				"https://n.news.naver.com/mnews/article/055/0001200003",
+			},
+		},
+		{
+			name: "naver headline limit", fixture: "naver_section.html", site: naverSite, mode: "headline", limit: "1",
+			want: []string{"https://n.news.naver.com/mnews/article/001/0015000001"},
+		},
+		{
+			name: "naver ranking", fixture: "naver_section.html", site: naverSite, mode: "ranking",
+			want: []string{
+				"https://n.news.naver.com/mnews/article/020/0003600004",
+				"https://n.news.naver.com/mnews/article/032/0003300005",
+			},
+		},
+		{
+			name: "daum headline", fixture: "daum_section.html", site: daumSite, mode: "headline",
+			want: []string{
+				"https://v.daum.net/v/20250102090000001",
+				"https://v.daum.net/v/20250102093000003",
+			},
+		},
+		{name: "unknown mode", fixture: "naver_section.html", site: naverSite, mode: "opinion", wantErr: true},
+		{name: "no links", fixture: "naver_article_empty.html", site: naverSite, mode: "headline", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HEADLINE_LIMIT", tt.limit)
+			doc, err := loadFixture(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ScrapeList(doc, tt.site, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ScrapeList error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScrapeList = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapeArticle(t *testing.T) {
+	useNaverURLs(t)
+	const (
+		naverURL = "https://n.news.naver.com/mnews/article/001/0015000001"
+		daumURL  = "https://v.daum.net/v/20250102090000001"
+		goneURL  = "https://n.news.naver.com/mnews/article/001/0015000099"
+	)
+	fetcher := fixtureFetcher{
+		naverURL: "naver_article.html",
+		daumURL:  "daum_article.html",
+		goneURL:  "naver_article_empty.html",
+	}
+	t.Setenv("DATE_SOURCES", "")
+	t.Setenv("RENDER_FALLBACK", "")
+	t.Setenv("READABILITY_FALLBACK", "")
+	t.Setenv("INCLUDE_RELATED", "true")
+	t.Setenv("SCRAPE_ENGAGEMENT", "true")
+
+	tests := []struct {
+		name    string
+		url     string
+		want    NewsArticle
+		wantErr bool
+	}{
+		{
+			name: "naver",
+			url:  naverURL,
+			want: NewsArticle{
+				Title:    "예산안 국회 통과",
+				Content:  "정부 예산안이 오늘 국회 본회의를 통과했다. 여야는 막판까지 쟁점 예산을 두고 협상했다.",
+				Date:     "2025-01-02T09:00:00+09:00",
+				URL:      naverURL,
+				Author:   "홍길동 기자, 김철수 기자",
+				ImageURL: "https://imgnews.pstatic.net/image/001/2025/01/02/budget.jpg",
+				ID:       "001_0015000001",
+				Related: []RelatedArticle{
+					{Title: "예산안 쟁점 정리", URL: "https://n.news.naver.com/mnews/article/001/0015000010"},
+					{Title: "여야 협상 뒷이야기", URL: "https://n.news.naver.com/mnews/article/001/0015000011"},
+				},
+				Comments:  1234,
+				Reactions: 12000,
+			},
+		},
+		{
+			name: "daum",
+			url:  daumURL,
+			want: NewsArticle{
+				Title:    "환율 1,400원 돌파",
+				Content:  "원·달러 환율이 1,400원을 넘어섰다. 외환 당국은 시장을 주시하고 있다.",
+				Date:     "2025. 1. 2. 09:00",
+				URL:      daumURL,
+				Author:   "이영희 기자",
+				ImageURL: "https://v.daum.net/thumb/exchange.jpg",
+				ID:       ArticleID(daumURL),
+				Related: []RelatedArticle{
+					{Title: "외환보유액 감소", URL: "https://v.daum.net/v/20250102080000004"},
+					{Title: "환율 전망", URL: "https://news.daum.net/v/20250101170000005"},
+				},
+			},
+		},
+		{name: "missing content", url: goneURL, wantErr: true},
+		{name: "fetch error", url: "https://n.news.naver.com/mnews/article/001/0000000000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ScrapeArticle(context.Background(), fetcher, tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ScrapeArticle error = %v, want error %v", err, tt.wantErr)
+			}
+			// 본문 줄바꿈과 들여쓰기는 템플릿마다 달라 공백을 하나로 모아 비교
+			got.Content = strings.Join(strings.Fields(got.Content), " ")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScrapeArticle =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArticleID(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://n.news.naver.com/mnews/article/001/0015000001", "001_0015000001"},
+		{"https://n.news.naver.com/article/001/0015000001?sid=100", "001_0015000001"},
+		{"https://m.news.naver.com/mnews/article/023/0003800002#comment", "023_0003800002"},
+		{"https://news.naver.com/main/read.naver?mode=LSD&oid=055&aid=0001200003", "055_0001200003"},
+		// 그 외 주소는 query 와 fragment, 끝의 / 를 뺀 주소의 SHA-256 앞 16자리
+		{"https://v.daum.net/v/20250102090000001", "3a489ba6e720d65d"},
+		{"https://V.DAUM.NET/v/20250102090000001/?from=home#top", "3a489ba6e720d65d"},
+		{"https://news.example.com/politics/1", "88826df5340e5bcb"},
+		{"https://news.naver.com/main/read.naver?oid=055", ArticleID("https://news.naver.com/main/read.naver")},
+	}
+	for _, tt := range tests {
+		if got := ArticleID(tt.url); got != tt.want {
+			t.Errorf("ArticleID(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+	if got := ArticleID("%zz"); len(got) != 16 {
+		t.Errorf("ArticleID of an unparsable URL = %q, want a 16 character hash", got)
+	}
+}
+
+func TestDateSources(t *testing.T) {
+	naver, err := loadFixture("naver_article.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daum, err := loadFixture("daum_article.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 예전 템플릿은 표시 요소가 아닌 곳에 data-date-time 을 둠
+	legacy, err := goquery.NewDocumentFromReader(strings.NewReader(
+		`<div class="sponsor"><span class="t11" data-date-time="2024-12-31 23:59:00">2024.12.31. 오후 11:59</span></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 다른 속성 이름을 쓰는 사이트
+	custom, err := goquery.NewDocumentFromReader(strings.NewReader(
+		`<span class="num_date" data-date="2025-01-02 09:00:00" data-date-time="1999-01-01 00:00:00">2025. 1. 2. 09:00</span>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	customSite := &SiteConfig{Name: "custom", Date: ".num_date", DateAttr: "data-date"}
+
+	tests := []struct {
+		source string
+		doc    *goquery.Document
+		site   *SiteConfig
+		want   string
+	}{
+		{"meta", naver, naverSite, "2025-01-02T09:00:00+09:00"},
+		{"attr", naver, naverSite, "2025-01-02 09:00:00"},
+		{"text", naver, naverSite, "2025.01.02. 오전 9:00"},
+		{"meta", daum, daumSite, ""},
+		{"attr", daum, daumSite, ""},
+		{"text", daum, daumSite, "2025. 1. 2. 09:00"},
+		{"meta", legacy, naverSite, ""},
+		{"attr", legacy, naverSite, "2024-12-31 23:59:00"},
+		{"text", legacy, naverSite, ""},
+		{"attr", custom, customSite, "2025-01-02 09:00:00"},
+		{"attr", custom, daumSite, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.TrimSpace(dateSources[tt.source](tt.doc, tt.site)); got != tt.want {
+			t.Errorf("%s source on %s template = %q, want %q", tt.source, tt.site.Name, got, tt.want)
+		}
+	}
+}
+
+func TestArticleDateOrder(t *testing.T) {
+	doc, err := loadFixture("naver_article.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{"", "2025-01-02T09:00:00+09:00"},
+		{"text,meta", "2025.01.02. 오전 9:00"},
+		{" attr , text", "2025-01-02 09:00:00"},
+		{"unknown,text", "2025.01.02. 오전 9:00"},
+		{"unknown", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("DATE_SOURCES", tt.order)
+		if got := ArticleDate(doc, naverSite); got != tt.want {
+			t.Errorf("ArticleDate with DATE_SOURCES=%q = %q, want %q", tt.order, got, tt.want)
+		}
+	}
+
+	// 앞 순서의 값이 비어 있으면 다음 값으로 넘어감
+	daum, err := loadFixture("daum_article.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DATE_SOURCES", "meta,attr,text")
+	if got, want := ArticleDate(daum, daumSite), "2025. 1. 2. 09:00"; got != want {
+		t.Errorf("ArticleDate of daum = %q, want %q", got, want)
+	}
+}
+
+func TestFetchHTMLUsesProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 프록시는 절대 주소로 요청을 받음
+		proxied = append(proxied, r.URL.String())
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><body><h2 class="media_end_head_headline">프록시 경유</h2></body></html>`))
+	}))
+	defer proxy.Close()
+
+	transport := model.NewTransport()
+	if err := setProxy(transport, proxy.URL); err != nil {
+		t.Fatal(err)
+	}
+	original := httpClient.Transport
+	httpClient.Transport = transport
+	t.Cleanup(func() { httpClient.Transport = original })
+	t.Setenv("RESPECT_ROBOTS", "false")
+
+	const page = "http://news.example.invalid/mnews/article/001/0015000001"
+	doc, err := FetchHTMLWithRetry(context.Background(), page, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Find(".media_end_head_headline").Text(); got != "프록시 경유" {
+		t.Errorf("fetched headline = %q, want the page served by the proxy", got)
+	}
+	if len(proxied) != 1 || proxied[0] != page {
+		t.Errorf("proxy received %q, want %q", proxied, page)
+	}
+}
+
+func TestSetProxyRejectsUnsupportedScheme(t *testing.T) {
+	for _, proxy := range []string{"ftp://proxy.example:21", "proxy.example:8080", "http://%zz"} {
+		transport := model.NewTransport()
+		if err := setProxy(transport, proxy); err == nil {
+			t.Errorf("setProxy(%q) succeeded, want an error", proxy)
+		}
+	}
+	for _, proxy := range []string{"http://proxy.example:8080", "https://proxy.example", "socks5://proxy.example:1080", "socks5h://proxy.example:1080"} {
+		transport := model.NewTransport()
+		if err := setProxy(transport, proxy); err != nil {
+			t.Errorf("setProxy(%q) = %v", proxy, err)
+		}
+	}
+}
+
+func TestRobotsMatchesSentUserAgent(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: BlockedBot\nDisallow: /mnews/\n\nUser-agent: *\nDisallow: /private/\n"))
+			return
+		}
+		sent = append(sent, r.Header.Get("User-Agent"))
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>기사</body></html>"))
+	}))
+	defer server.Close()
+	t.Setenv("RESPECT_ROBOTS", "")
+	t.Setenv("USER_AGENTS", "BlockedBot/1.0,FriendlyBot/1.0")
+	resetRobotsCache()
+	t.Cleanup(resetRobotsCache)
+	pick := 0
+	userAgentIntn = func(int) int { return pick }
+	t.Cleanup(func() { userAgentIntn = rand.Intn })
+
+	page := server.URL + "/mnews/article/001/0015000001"
+	_, err := FetchHTMLWithRetry(context.Background(), page, 1, 0)
+	var disallowed *RobotsDisallowedError
+	if !errors.As(err, &disallowed) {
+		t.Fatalf("fetch as BlockedBot = %v, want RobotsDisallowedError", err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("page was requested as %q despite robots.txt", sent)
+	}
+
+	pick = 1
+	if _, err := FetchHTMLWithRetry(context.Background(), page, 1, 0); err != nil {
+		t.Fatalf("fetch as FriendlyBot = %v", err)
+	}
+	if _, err := FetchHTMLWithRetry(context.Background(), server.URL+"/private/page", 1, 0); !errors.As(err, &disallowed) {
+		t.Errorf("fetch of /private/ as FriendlyBot = %v, want RobotsDisallowedError", err)
+	}
+	if len(sent) != 1 || sent[0] != "FriendlyBot/1.0" {
+		t.Errorf("page requests sent User-Agents %q, want the one robots.txt was matched against", sent)
+	}
+}
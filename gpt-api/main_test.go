@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sniij/mircro-services-golang/model/secrets"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// chunkedRequest returns a request that GPT_MAX_INPUT_TOKENS=110 splits into three chunks.
+func chunkedRequest(t *testing.T) GPTRequest {
+	t.Helper()
+	t.Setenv("GPT_MAX_INPUT_TOKENS", "110")
+	t.Setenv("SYSTEM_PROMPT", "")
+	paragraph := strings.Repeat("word ", 6)
+	return GPTRequest{Content: paragraph + "\n" + paragraph + "\n" + paragraph}
+}
+
+func TestCompleteChunkedKeepsDeadlineError(t *testing.T) {
+	request := chunkedRequest(t)
+	calls := 0
+	once := func(ctx context.Context, gptRequest GPTRequest) (ChatResult, error) {
+		calls++
+		if calls == 2 {
+			return ChatResult{}, context.DeadlineExceeded
+		}
+		return ChatResult{Content: gptRequest.Content}, nil
+	}
+
+	_, err := completeChunked(context.Background(), request, once)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want it to wrap context.DeadlineExceeded", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestCompleteChunkedSharesDeadline(t *testing.T) {
+	request := chunkedRequest(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var budgets []time.Duration
+	once := func(ctx context.Context, gptRequest GPTRequest) (ChatResult, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Fatal("chunk context has no deadline")
+		}
+		budgets = append(budgets, time.Until(deadline))
+		return ChatResult{Content: gptRequest.Content}, nil
+	}
+
+	if _, err := completeChunked(ctx, request, once); err != nil {
+		t.Fatal(err)
+	}
+	if len(budgets) != 3 {
+		t.Fatalf("chunks = %d, want 3", len(budgets))
+	}
+	// 첫 청크는 남은 시간의 1/3, 마지막 청크는 남은 시간 전부
+	if budgets[0] > 1100*time.Millisecond || budgets[0] < 900*time.Millisecond {
+		t.Errorf("first chunk budget = %v, want about 1s", budgets[0])
+	}
+	if budgets[2] < 2800*time.Millisecond {
+		t.Errorf("last chunk budget = %v, want the rest of the 3s", budgets[2])
+	}
+}
+
+// roundTripFunc serves HTTP requests in process.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestCompletionRequestTemperature(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", "absent"},
+		{"0", "present"},
+		{"0.7", "present"},
+		{"3", "absent"},
+	}
+	for _, tt := range tests {
+		t.Setenv("GPT_TEMPERATURE", tt.value)
+		body, err := json.Marshal(completionRequest(GPTRequest{}, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]interface{}
+		json.Unmarshal(body, &fields)
+		temperature, ok := fields["temperature"].(float64)
+		if got := map[bool]string{true: "present", false: "absent"}[ok]; got != tt.want {
+			t.Errorf("GPT_TEMPERATURE=%q: temperature %s in %s, want %s", tt.value, got, body, tt.want)
+		}
+		if tt.value == "0" && temperature > 1e-6 {
+			t.Errorf("GPT_TEMPERATURE=0 sent temperature %v", temperature)
+		}
+	}
+}
+
+func TestAnthropicSendsZeroTemperature(t *testing.T) {
+	t.Setenv("GPT_TEMPERATURE", "0")
+	var sent map[string]interface{}
+	completer := AnthropicCompleter{Client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		json.NewDecoder(req.Body).Decode(&sent)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)),
+		}, nil
+	})}}
+
+	if _, err := completer.Complete(context.Background(), GPTRequest{Content: "본문"}); err != nil {
+		t.Fatal(err)
+	}
+	if temperature, ok := sent["temperature"]; !ok || temperature != 0.0 {
+		t.Errorf("temperature = %v (sent %v), want 0", temperature, ok)
+	}
+}
+
+// newSecretsManager starts a stand-in for Secrets Manager returning secret as the
+// SecretString of every secret. It returns a func reporting the region of the last
+// signed request.
+func newSecretsManager(t *testing.T, secret string) func() string {
+	t.Helper()
+	var region string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Credential=<key>/<date>/<region>/secretsmanager/aws4_request
+		if _, credential, ok := strings.Cut(r.Header.Get("Authorization"), "Credential="); ok {
+			if scope := strings.Split(credential, "/"); len(scope) > 2 {
+				region = scope[2]
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]string{"Name": "gpt-api", "SecretString": secret})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	return func() string { return region }
+}
+
+func TestOpenAIKeyLoadsSecretsFirst(t *testing.T) {
+	region := newSecretsManager(t, `{"GPT_API_KEY": "sk-from-secret"}`)
+	t.Setenv("SECRETS_SOURCE", "secretsmanager")
+	t.Setenv("SECRETS_NAME", "gpt-api")
+	t.Setenv("SECRETS_REGION", "eu-west-1")
+	t.Setenv("GPT_API_KEY", "sk-from-env")
+	apiKey = ""
+	secrets.Reset()
+	t.Cleanup(func() {
+		apiKey = ""
+		secrets.Reset()
+	})
+
+	key, err := OpenAIKey(context.Background())
+	if err != nil || key != "sk-from-secret" {
+		t.Errorf("OpenAIKey = (%q, %v), want the key of the secret", key, err)
+	}
+	if got := region(); got != "eu-west-1" {
+		t.Errorf("Secrets Manager region = %q, want SECRETS_REGION", got)
+	}
+}
+
+// stubOpenAI routes the requests of the clients newCompleter builds to a test server
+// answering with handler, and configures Handler to use the OpenAI backend.
+func stubOpenAI(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	original := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme, req.URL.Host = "http", server.Listener.Addr().String()
+		return server.Client().Transport.RoundTrip(req)
+	})
+	t.Cleanup(func() { http.DefaultTransport = original })
+
+	t.Setenv("LLM_PROVIDER", "openai")
+	t.Setenv("SECRETS_SOURCE", "")
+	t.Setenv("OPENAI_KEY_SECRET_ARN", "")
+	t.Setenv("OPENAI_KEY_SSM_NAME", "")
+	t.Setenv("GPT_API_KEY", "sk-test")
+	t.Setenv("SIGNATURE_SECRET", "")
+	t.Setenv("GPT_MAX_RETRIES", "0")
+	t.Setenv("RESPONSE_FORMAT", "")
+	apiKey = ""
+	t.Cleanup(func() { apiKey = "" })
+}
+
+// chatCompletion writes an OpenAI chat completion response with the given choices.
+func chatCompletion(w http.ResponseWriter, choices ...map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"choices": append([]map[string]interface{}{}, choices...),
+		"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+	})
+}
+
+// callHandler sends a request for content to Handler and decodes the error of a
+// non-200 response.
+func callHandler(t *testing.T, content string) (events.APIGatewayProxyResponse, string) {
+	t.Helper()
+	body, _ := json.Marshal(GPTRequest{Content: content, Prompt: "정리해주세요"})
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if err != nil {
+		t.Fatalf("Handler error = %v, want it reported in the response", err)
+	}
+	var failure struct {
+		Error string `json:"error"`
+	}
+	if response.StatusCode != http.StatusOK {
+		if err := json.Unmarshal([]byte(response.Body), &failure); err != nil {
+			t.Errorf("error body %q is not JSON: %v", response.Body, err)
+		}
+	}
+	return response, failure.Error
+}
+
+func TestHandlerReportsChatCompletionError(t *testing.T) {
+	calls := 0
+	stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "context length exceeded", "type": "invalid_request_error"}}`))
+			return
+		}
+		chatCompletion(w, map[string]interface{}{"index": 0, "message": map[string]string{"role": "assistant", "content": "정리된 본문"}, "finish_reason": "stop"})
+	})
+
+	response, message := callHandler(t, "본문")
+	if response.StatusCode != http.StatusInternalServerError || !strings.Contains(message, "context length exceeded") {
+		t.Errorf("Handler = %d %s, want 500 with the OpenAI error", response.StatusCode, response.Body)
+	}
+
+	// 실패 후에도 같은 프로세스가 다음 요청을 처리함
+	response, _ = callHandler(t, "본문")
+	if response.StatusCode != http.StatusOK || response.Body != "정리된 본문" {
+		t.Errorf("next Handler call = %d %s, want 200 with the reply", response.StatusCode, response.Body)
+	}
+}
+
+func TestHandlerReportsCompletionWithoutContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		choices []map[string]interface{}
+		want    string
+	}{
+		{"no choices", nil, "no choices"},
+		{
+			"content filter",
+			[]map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": ""}, "finish_reason": "content_filter"}},
+			"finish reason: content_filter",
+		},
+		{
+			"length",
+			[]map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": ""}, "finish_reason": "length"}},
+			"finish reason: length",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+				chatCompletion(w, tt.choices...)
+			})
+
+			response, message := callHandler(t, "본문")
+			if response.StatusCode != http.StatusInternalServerError || !strings.Contains(message, tt.want) {
+				t.Errorf("Handler = %d %s, want 500 with %q", response.StatusCode, response.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// CategoryIcon returns the icon of category from CATEGORY_ICONS, a JSON object such
+// as {"politics": "🏛️", "economy": "💹"}. Unset or unknown means no icon.
+// convert-to-markdown puts it in the heading and auto-push in the index entries.
+func CategoryIcon(category string) string {
+	value := os.Getenv("CATEGORY_ICONS")
+	if value == "" || category == "" {
+		return ""
+	}
+	var icons map[string]string
+	if err := json.Unmarshal([]byte(value), &icons); err != nil {
+		logger.Warn("invalid CATEGORY_ICONS, using no icon", "step", "config", "status", "fallback", "error", err)
+		return ""
+	}
+	return icons[category]
+}
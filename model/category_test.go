@@ -0,0 +1,18 @@
+package model
+
+import "testing"
+
+func TestCategoryIcon(t *testing.T) {
+	t.Setenv("CATEGORY_ICONS", `{"politics": "🏛️"}`)
+	if got := CategoryIcon("politics"); got != "🏛️" {
+		t.Errorf("CategoryIcon(politics) = %q, want 🏛️", got)
+	}
+	if got := CategoryIcon("economy"); got != "" {
+		t.Errorf("CategoryIcon of an unknown category = %q, want none", got)
+	}
+
+	t.Setenv("CATEGORY_ICONS", `{"politics": `)
+	if got := CategoryIcon("politics"); got != "" {
+		t.Errorf("CategoryIcon with invalid CATEGORY_ICONS = %q, want none", got)
+	}
+}
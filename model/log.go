@@ -0,0 +1,10 @@
+package model
+
+import (
+	"log/slog"
+	"os"
+)
+
+// logger writes one JSON object per line, like the auto-push logs, so the timings and
+// warnings of the shared helpers can be queried by step in CloudWatch Logs Insights.
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
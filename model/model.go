@@ -0,0 +1,65 @@
+// Package model holds the request and response types shared by the services, so a
+// field added to an article is declared once for crawling, convert-to-markdown,
+// auto-push and gpt-api, and the request signing every service checks.
+package model
+
+// NewsArticle represents a news article with title and content.
+// crawling fills it in, auto-push passes it on and convert-to-markdown renders it.
+type NewsArticle struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Date    string `json:"date"`
+	URL     string `json:"url"`
+	// Author is the byline, empty when the page has none
+	Author string `json:"author,omitempty"`
+	// ImageURL is the lead image, empty when the page has none
+	ImageURL string `json:"image_url,omitempty"`
+	// ID is a stable identifier derived from URL
+	ID string `json:"id"`
+	// FallbackExtracted marks articles extracted by the generic fallback, whose quality is uncertain
+	FallbackExtracted bool `json:"fallback_extracted,omitempty"`
+	// Related lists the related-article links when INCLUDE_RELATED=true
+	Related []RelatedArticle `json:"related,omitempty"`
+	// Comments and Reactions are engagement counts scraped when SCRAPE_ENGAGEMENT=true
+	Comments  int `json:"comments,omitempty"`
+	Reactions int `json:"reactions,omitempty"`
+	// Category is the section the article was scraped from, set by auto-push
+	Category string `json:"category,omitempty"`
+	// RawDate keeps the scraped date string when KEEP_RAW_DATE=true
+	RawDate string `json:"raw_date,omitempty"`
+	// Stats is set on the converted content when ARTICLE_STATS=true
+	Stats *ArticleStats `json:"stats,omitempty"`
+}
+
+// RelatedArticle is a related-story link listed on an article page.
+type RelatedArticle struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// ArticleStats holds length metadata of the converted content for reading-time estimates.
+type ArticleStats struct {
+	Characters         int `json:"characters"`
+	Words              int `json:"words"`
+	ReadingTimeMinutes int `json:"reading_time_minutes"`
+}
+
+// GPTRequest represents the payload for the GPT server.
+type GPTRequest struct {
+	Content string `json:"content"`
+	Prompt  string `json:"prompt"`
+	// Model overrides GPT_MODEL for this request, e.g. gpt-4 for harder cleanups
+	Model string `json:"model,omitempty"`
+	// System overrides SYSTEM_PROMPT for this request
+	System string `json:"system,omitempty"`
+}
+
+// ConvertResult is one element of the JSON array convert-to-markdown answers a batch
+// of articles with. Status and Error mirror the single-article response, and Failures
+// its X-GPT-Failures header.
+type ConvertResult struct {
+	Status   int      `json:"status"`
+	Document string   `json:"document,omitempty"`
+	Failures []string `json:"failures,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
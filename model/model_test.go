@@ -0,0 +1,84 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// The JSON each service exchanged before the types moved here.
+const (
+	crawlingArticleJSON = `{"title":"제목","content":"본문","date":"2025.01.04. 오후 3:25","url":"https://n.news.naver.com/article/001/0001","author":"홍길동 기자","image_url":"https://imgnews.pstatic.net/1.jpg","id":"001-0001","fallback_extracted":true,"related":[{"title":"관련 기사","url":"https://n.news.naver.com/article/001/0002"}],"comments":12,"reactions":3}`
+	autoPushArticleJSON = `{"title":"제목","content":"본문","date":"2025.01.04. 오후 3:25","url":"https://n.news.naver.com/article/001/0001","id":"001-0001","category":"politics","related":[{"title":"관련 기사","url":"https://n.news.naver.com/article/001/0002"}]}`
+	convertArticleJSON  = `{"title":"제목","content":"본문","date":"2025년 01월 04일 오후 3시 25분","url":"https://n.news.naver.com/article/001/0001","raw_date":"2025.01.04. 오후 3:25","category":"politics","stats":{"characters":2,"words":1,"reading_time_minutes":1}}`
+	convertGPTJSON      = `{"content":"본문","prompt":"정리해주세요"}`
+	gptAPIRequestJSON   = `{"content":"본문","prompt":"정리해주세요","model":"gpt-4","system":"You clean news articles."}`
+)
+
+// roundTrip decodes old into a value of the type of v, encodes it again and checks
+// that the result keeps every field of old with its value. Fields old did not have
+// may only appear empty, like the id convert-to-markdown never sent.
+func roundTrip(t *testing.T, old string, v interface{}) []byte {
+	t.Helper()
+	if err := json.Unmarshal([]byte(old), v); err != nil {
+		t.Fatalf("decoding %s: %v", old, err)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got map[string]interface{}
+	json.Unmarshal([]byte(old), &want)
+	json.Unmarshal(encoded, &got)
+	for name, value := range got {
+		if _, ok := want[name]; !ok && !reflect.ValueOf(value).IsZero() {
+			t.Errorf("round trip of %s added %s: %v", old, name, value)
+		}
+		delete(got, name)
+		if _, ok := want[name]; ok {
+			got[name] = value
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip of\n%s\ngave\n%s", old, encoded)
+	}
+	return encoded
+}
+
+func TestNewsArticleRoundTrip(t *testing.T) {
+	var crawled NewsArticle
+	// crawling 의 필드 순서는 그대로라서 바이트 단위로도 같아야 함
+	if encoded := roundTrip(t, crawlingArticleJSON, &crawled); string(encoded) != crawlingArticleJSON {
+		t.Errorf("crawling JSON changed:\n got %s\nwant %s", encoded, crawlingArticleJSON)
+	}
+	if crawled.Author != "홍길동 기자" || !crawled.FallbackExtracted || len(crawled.Related) != 1 || crawled.Comments != 12 {
+		t.Errorf("decoded crawling article = %+v", crawled)
+	}
+
+	var pushed NewsArticle
+	roundTrip(t, autoPushArticleJSON, &pushed)
+	if pushed.Category != "politics" {
+		t.Errorf("decoded auto-push category = %q, want politics", pushed.Category)
+	}
+
+	var converted NewsArticle
+	roundTrip(t, convertArticleJSON, &converted)
+	if converted.RawDate != "2025.01.04. 오후 3:25" || converted.Stats == nil || converted.Stats.Words != 1 {
+		t.Errorf("decoded convert-to-markdown article = %+v", converted)
+	}
+}
+
+func TestGPTRequestRoundTrip(t *testing.T) {
+	var fromConvert GPTRequest
+	if encoded := roundTrip(t, convertGPTJSON, &fromConvert); string(encoded) != convertGPTJSON {
+		t.Errorf("convert-to-markdown request changed:\n got %s\nwant %s", encoded, convertGPTJSON)
+	}
+
+	var fromAPI GPTRequest
+	if encoded := roundTrip(t, gptAPIRequestJSON, &fromAPI); string(encoded) != gptAPIRequestJSON {
+		t.Errorf("gpt-api request changed:\n got %s\nwant %s", encoded, gptAPIRequestJSON)
+	}
+	if fromAPI.Model != "gpt-4" || fromAPI.System == "" {
+		t.Errorf("decoded gpt-api request = %+v", fromAPI)
+	}
+}
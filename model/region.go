@@ -0,0 +1,25 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultRegion is the region the services run in when nothing else is configured.
+const DefaultRegion = "ap-northeast-2"
+
+// Region returns the AWS region from the first of names that is set, else the
+// AWS_REGION set by Lambda, else DefaultRegion. A variable that is set but blank is
+// an error rather than a silent default.
+func Region(names ...string) (string, error) {
+	for _, name := range append(names, "AWS_REGION") {
+		if value, ok := os.LookupEnv(name); ok {
+			if region := strings.TrimSpace(value); region != "" {
+				return region, nil
+			}
+			return "", fmt.Errorf("%s is set but empty", name)
+		}
+	}
+	return DefaultRegion, nil
+}
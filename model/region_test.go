@@ -0,0 +1,34 @@
+package model
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRegion(t *testing.T) {
+	t.Setenv("S3_REGION", "")
+	t.Setenv("AWS_REGION", "")
+	os.Unsetenv("S3_REGION")
+	os.Unsetenv("AWS_REGION")
+	if got, err := Region("S3_REGION"); err != nil || got != DefaultRegion {
+		t.Errorf("Region with nothing set = (%q, %v), want %q", got, err, DefaultRegion)
+	}
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	if got, err := Region("S3_REGION"); err != nil || got != "us-east-1" {
+		t.Errorf("Region with AWS_REGION = (%q, %v), want us-east-1", got, err)
+	}
+
+	t.Setenv("S3_REGION", " eu-west-1 ")
+	if got, err := Region("S3_REGION"); err != nil || got != "eu-west-1" {
+		t.Errorf("Region with S3_REGION = (%q, %v), want eu-west-1", got, err)
+	}
+	if got, err := Region(); err != nil || got != "us-east-1" {
+		t.Errorf("Region() = (%q, %v), want AWS_REGION", got, err)
+	}
+
+	t.Setenv("S3_REGION", " ")
+	if got, err := Region("S3_REGION"); err == nil {
+		t.Errorf("Region with blank S3_REGION = %q, want an error", got)
+	}
+}
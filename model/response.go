@@ -0,0 +1,18 @@
+package model
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// JSONBody encodes a response body with json.Marshal, so quotes and backslashes in
+// error messages, keys and URLs cannot break the JSON. SDK and network errors quote
+// the URL they failed on, e.g. Put "https://...": dial tcp ...
+func JSONBody(fields map[string]interface{}) string {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		log.Printf("Error encoding JSON: %v", err)
+		return `{"error": "Failed to encoding JSON"}`
+	}
+	return string(body)
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestJSONBodyEscapesErrors(t *testing.T) {
+	err := &url.Error{Op: "Put", URL: `https://news.s3.amazonaws.com/news/"a".md`, Err: errors.New("dial tcp: i/o timeout")}
+	body := JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to upload file: %v", err), "drained": 2})
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		t.Fatalf("JSONBody = %s, not JSON: %v", body, err)
+	}
+	if want := "Failed to upload file: " + err.Error(); decoded["error"] != want {
+		t.Errorf("error = %q, want %q", decoded["error"], want)
+	}
+	if decoded["drained"] != float64(2) {
+		t.Errorf("drained = %v, want 2", decoded["drained"])
+	}
+}
@@ -0,0 +1,84 @@
+// Package secrets loads the JSON secret the services share through SECRETS_NAME.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Sniij/mircro-services-golang/model"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// fields holds the fields of the SECRETS_NAME secret once Load has fetched it.
+var (
+	mu     sync.Mutex
+	fields map[string]string
+)
+
+// Load fetches the JSON secret named by SECRETS_NAME from Secrets Manager when
+// SECRETS_SOURCE=secretsmanager, and keeps it for the lifetime of the container.
+// A failed fetch is not cached so the next call retries it.
+func Load(ctx context.Context) error {
+	if os.Getenv("SECRETS_SOURCE") != "secretsmanager" {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fields != nil {
+		return nil
+	}
+
+	name := os.Getenv("SECRETS_NAME")
+	if name == "" {
+		return fmt.Errorf("SECRETS_SOURCE is secretsmanager but SECRETS_NAME is not set")
+	}
+	region, err := Region()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	output, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get secret value: %v", err)
+	}
+	var loaded map[string]string
+	if err := json.Unmarshal([]byte(aws.ToString(output.SecretString)), &loaded); err != nil {
+		return fmt.Errorf("secret %s is not a JSON object: %v", name, err)
+	}
+	fields = loaded
+	return nil
+}
+
+// Region returns the region of Secrets Manager and SSM: SECRETS_REGION, or the
+// AWS_REGION set by Lambda, or model.DefaultRegion (see model.Region).
+func Region() (string, error) {
+	return model.Region("SECRETS_REGION")
+}
+
+// Get returns name from the loaded secret, falling back to the env var of the
+// same name when the secret does not hold it or SECRETS_SOURCE is unset.
+func Get(name string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	if value := fields[name]; value != "" {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// Reset drops the loaded secret so the next Load fetches it again.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	fields = nil
+}
@@ -0,0 +1,59 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadUsesConfiguredRegion(t *testing.T) {
+	var region string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Credential=<key>/<date>/<region>/secretsmanager/aws4_request
+		if _, credential, ok := strings.Cut(r.Header.Get("Authorization"), "Credential="); ok {
+			if scope := strings.Split(credential, "/"); len(scope) > 2 {
+				region = scope[2]
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]string{"Name": "upload-to-github", "SecretString": `{"TOKEN_GITHUB": "ghp-from-secret"}`})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("SECRETS_SOURCE", "secretsmanager")
+	t.Setenv("SECRETS_NAME", "upload-to-github")
+	t.Setenv("SECRETS_REGION", "")
+	os.Unsetenv("SECRETS_REGION")
+	t.Setenv("AWS_REGION", "us-east-1")
+	Reset()
+	t.Cleanup(Reset)
+
+	if err := Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get("TOKEN_GITHUB"); got != "ghp-from-secret" {
+		t.Errorf("TOKEN_GITHUB = %q, want the value of the secret", got)
+	}
+	if region != "us-east-1" {
+		t.Errorf("Secrets Manager region = %q, want AWS_REGION", region)
+	}
+}
+
+func TestGetFallsBackToEnv(t *testing.T) {
+	t.Setenv("SECRETS_SOURCE", "")
+	t.Setenv("TOKEN_GITHUB", "ghp-from-env")
+	Reset()
+
+	if err := Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get("TOKEN_GITHUB"); got != "ghp-from-env" {
+		t.Errorf("TOKEN_GITHUB = %q, want the env var", got)
+	}
+}
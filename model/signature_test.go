@@ -0,0 +1,71 @@
+package model
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// signedHeaders signs payload with secret and returns the headers as API Gateway passes them.
+func signedHeaders(t *testing.T, secret string, payload []byte) map[string]string {
+	t.Helper()
+	req, err := http.NewRequest("POST", "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SignRequest(req, secret, payload)
+	return map[string]string{
+		"X-Signature":           req.Header.Get("x-signature"),
+		"X-Signature-Timestamp": req.Header.Get("x-signature-timestamp"),
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	t.Setenv("REQUIRE_SIGNATURE", "true")
+	getSecret := func(name string) string {
+		if name != "SIGNATURE_SECRET" {
+			t.Errorf("getSecret(%q), want SIGNATURE_SECRET", name)
+		}
+		return "secret"
+	}
+	payload := []byte(`{"title": "제목"}`)
+
+	if !VerifySignature(signedHeaders(t, "secret", payload), payload, getSecret) {
+		t.Error("request signed with the secret was rejected")
+	}
+	if VerifySignature(signedHeaders(t, "other", payload), payload, getSecret) {
+		t.Error("request signed with another secret was accepted")
+	}
+	if VerifySignature(signedHeaders(t, "secret", payload), []byte("changed"), getSecret) {
+		t.Error("request with a changed payload was accepted")
+	}
+
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	headers := map[string]string{
+		"x-signature":           Signature("secret", stale, payload),
+		"x-signature-timestamp": stale,
+	}
+	if VerifySignature(headers, payload, getSecret) {
+		t.Error("request signed 10 minutes ago was accepted with the default 5m skew")
+	}
+	t.Setenv("SIGNATURE_MAX_SKEW", "15m")
+	if !VerifySignature(headers, payload, getSecret) {
+		t.Error("request signed 10 minutes ago was rejected with SIGNATURE_MAX_SKEW=15m")
+	}
+}
+
+func TestVerifySignatureNotRequired(t *testing.T) {
+	t.Setenv("REQUIRE_SIGNATURE", "")
+	if !VerifySignature(nil, nil, func(string) string { return "secret" }) {
+		t.Error("unsigned request was rejected without REQUIRE_SIGNATURE")
+	}
+}
+
+func TestSignRequestWithoutSecret(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://localhost", nil)
+	SignRequest(req, "", []byte("payload"))
+	if req.Header.Get("x-signature") != "" {
+		t.Error("request was signed with an empty secret")
+	}
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"os"
+	"time"
+)
+
+// LogTiming logs the elapsed milliseconds since start when LOG_TIMINGS=true.
+// Call it deferred at the top of the operation: defer model.LogTiming("step", id, time.Now())
+func LogTiming(step string, id string, start time.Time) {
+	if os.Getenv("LOG_TIMINGS") == "true" {
+		logger.Info("timing", "step", step, "id", id, "elapsed_ms", time.Since(start).Milliseconds())
+	}
+}
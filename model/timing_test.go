@@ -0,0 +1,37 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLogTimingWritesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	original := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	t.Cleanup(func() { logger = original })
+
+	t.Setenv("LOG_TIMINGS", "")
+	LogTiming("PutObject", "news/a.md", time.Now())
+	if buf.Len() != 0 {
+		t.Fatalf("LogTiming without LOG_TIMINGS wrote %q", buf.String())
+	}
+
+	t.Setenv("LOG_TIMINGS", "true")
+	LogTiming("PutObject", "news/a.md", time.Now().Add(-50*time.Millisecond))
+	var entry struct {
+		Msg       string `json:"msg"`
+		Step      string `json:"step"`
+		ID        string `json:"id"`
+		ElapsedMS int64  `json:"elapsed_ms"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("LogTiming wrote %q, want a JSON line: %v", buf.String(), err)
+	}
+	if entry.Msg != "timing" || entry.Step != "PutObject" || entry.ID != "news/a.md" || entry.ElapsedMS < 50 {
+		t.Errorf("LogTiming entry = %+v", entry)
+	}
+}
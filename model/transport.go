@@ -0,0 +1,43 @@
+package model
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NewTransport builds the transport of the services' HTTP clients from env. The
+// defaults match http.DefaultTransport: HTTP_MAX_IDLE_CONNS_PER_HOST=2,
+// HTTP_IDLE_CONN_TIMEOUT=90s, and HTTP/2 enabled unless HTTP_DISABLE_HTTP2=true.
+func NewTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if value := os.Getenv("HTTP_MAX_IDLE_CONNS_PER_HOST"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			logger.Warn("invalid HTTP_MAX_IDLE_CONNS_PER_HOST, using default", "step", "config", "value", value, "status", "fallback")
+		} else {
+			transport.MaxIdleConnsPerHost = n
+			// 전체 유휴 연결 수가 호스트별 한도보다 작으면 한도가 무의미해짐
+			if transport.MaxIdleConns < n {
+				transport.MaxIdleConns = n
+			}
+		}
+	}
+	if value := os.Getenv("HTTP_IDLE_CONN_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			logger.Warn("invalid HTTP_IDLE_CONN_TIMEOUT, using default", "step", "config", "value", value, "status", "fallback")
+		} else {
+			transport.IdleConnTimeout = d
+		}
+	}
+	if os.Getenv("HTTP_DISABLE_HTTP2") == "true" {
+		// 비어 있지 않은 TLSNextProto 맵이 HTTP/2 자동 업그레이드를 막음
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
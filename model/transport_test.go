@@ -0,0 +1,39 @@
+package model
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	t.Setenv("HTTP_MAX_IDLE_CONNS_PER_HOST", "")
+	t.Setenv("HTTP_IDLE_CONN_TIMEOUT", "")
+	t.Setenv("HTTP_DISABLE_HTTP2", "")
+	defaults := http.DefaultTransport.(*http.Transport)
+	transport := NewTransport()
+	if transport.MaxIdleConnsPerHost != defaults.MaxIdleConnsPerHost || transport.IdleConnTimeout != defaults.IdleConnTimeout || !transport.ForceAttemptHTTP2 {
+		t.Errorf("NewTransport without env = %+v, want the defaults of http.DefaultTransport", transport)
+	}
+
+	t.Setenv("HTTP_MAX_IDLE_CONNS_PER_HOST", "200")
+	t.Setenv("HTTP_IDLE_CONN_TIMEOUT", "30s")
+	t.Setenv("HTTP_DISABLE_HTTP2", "true")
+	transport = NewTransport()
+	if transport.MaxIdleConnsPerHost != 200 || transport.MaxIdleConns < 200 {
+		t.Errorf("idle conns = %d per host, %d total, want 200", transport.MaxIdleConnsPerHost, transport.MaxIdleConns)
+	}
+	if transport.IdleConnTimeout != 30*time.Second {
+		t.Errorf("IdleConnTimeout = %v, want 30s", transport.IdleConnTimeout)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("HTTP_DISABLE_HTTP2=true left HTTP/2 enabled")
+	}
+
+	t.Setenv("HTTP_MAX_IDLE_CONNS_PER_HOST", "-1")
+	t.Setenv("HTTP_IDLE_CONN_TIMEOUT", "soon")
+	transport = NewTransport()
+	if transport.MaxIdleConnsPerHost != defaults.MaxIdleConnsPerHost || transport.IdleConnTimeout != defaults.IdleConnTimeout {
+		t.Errorf("NewTransport with invalid env = %d, %v, want the defaults", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+}
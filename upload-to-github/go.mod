@@ -1,12 +1,14 @@
-module main
+module github.com/Sniij/mircro-services-golang/upload-to-github
 
 go 1.23
 
 toolchain go1.23.4
 
 require (
+	github.com/Sniij/mircro-services-golang/model v0.0.0
 	github.com/aws/aws-lambda-go v1.47.0
 	github.com/aws/aws-sdk-go-v2 v1.32.7
+	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.34.8
 )
 
 require (
@@ -36,3 +38,5 @@ require (
 	github.com/joho/godotenv v1.5.1
 	golang.org/x/oauth2 v0.24.0
 )
+
+replace github.com/Sniij/mircro-services-golang/model => ../model
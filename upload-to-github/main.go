@@ -2,20 +2,31 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Sniij/mircro-services-golang/model"
+	"github.com/Sniij/mircro-services-golang/model/secrets"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/google/go-github/v45/github"
 	"github.com/joho/godotenv"
 	"golang.org/x/oauth2"
@@ -36,6 +47,10 @@ func init() {
 }
 
 func main() {
+	// 콜드 스타트에 시크릿을 불러옴. 실패하면 Handler 가 호출마다 다시 시도
+	if err := secrets.Load(context.Background()); err != nil {
+		log.Printf("failed to load secrets: %v", err)
+	}
 	lambda.Start(Handler)
 }
 
@@ -70,8 +85,19 @@ func (d *S3Downloader) DownloadFile(ctx context.Context, key string) ([]byte, er
 	}
 	defer output.Body.Close()
 
+	var body io.Reader = output.Body
+	// upload-to-s3 의 COMPRESS_UPLOADS 로 압축 저장된 파일
+	if strings.HasSuffix(key, ".gz") {
+		gz, err := gzip.NewReader(output.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress S3 file content: %v", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
 	var buf bytes.Buffer
-	_, err = buf.ReadFrom(output.Body)
+	_, err = buf.ReadFrom(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read S3 file content: %v", err)
 	}
@@ -83,24 +109,197 @@ type GitHubUploader struct {
 	Client *github.Client
 	Owner  string
 	Repo   string
+	// Branch is the target branch name, see BranchRef
+	Branch string
 }
 
+// BranchRef returns the ref path of branch for GetRef ("heads/<branch>"). A leading
+// "refs/heads/" is accepted and stripped; an empty branch is an error.
+func BranchRef(branch string) (string, error) {
+	branch = strings.TrimPrefix(strings.TrimSpace(branch), "refs/heads/")
+	if branch == "" {
+		return "", fmt.Errorf("branch name is empty")
+	}
+	return "heads/" + branch, nil
+}
+
+// UploadFiles commits files to the target branch in a single commit. When the branch
+// moves between reading its HEAD and updating it (a 422 non-fast-forward), the commit
+// is rebuilt on the new HEAD, up to COMMIT_ATTEMPTS times in total (default 3).
+// Files identical to the branch are skipped, and ErrNoChanges is returned if none are left.
 func (u *GitHubUploader) UploadFiles(ctx context.Context, files map[string][]byte, commitMessage string) error {
-	// Get the reference to the HEAD of the default branch (e.g., main)
-	ref, _, err := u.Client.Git.GetRef(ctx, u.Owner, u.Repo, "heads/main")
+	defer model.LogTiming("GitHubCommit", fmt.Sprintf("files=%d", len(files)), time.Now())
+
+	branchRef, err := BranchRef(u.Branch)
+	if err != nil {
+		return err
+	}
+
+	attempts := 3
+	if value := os.Getenv("COMMIT_ATTEMPTS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			attempts = n
+		} else {
+			log.Printf("Invalid COMMIT_ATTEMPTS %q. Falling back to 3", value)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		base, err := u.commitFiles(ctx, branchRef, files, commitMessage)
+		if err == nil {
+			return nil
+		}
+		var conflict *refConflictError
+		if !errors.As(err, &conflict) || attempt == attempts {
+			return err
+		}
+		// 충돌한 새 HEAD 를 다시 읽어 어떤 커밋 위에 재시도하는지 남김
+		head := "unknown"
+		if ref, _, err := u.Client.Git.GetRef(ctx, u.Owner, u.Repo, branchRef); err == nil {
+			head = ref.Object.GetSHA()
+		}
+		log.Printf("Branch moved from %s to %s while committing (attempt %d/%d). Rebuilding on the new HEAD", base, head, attempt, attempts)
+	}
+}
+
+// OpenPullRequest commits files to a new branch off the target branch and opens a pull
+// request back into it, returning the pull request URL. When no pull request is opened
+// the branch is deleted again, and ErrNoChanges is returned if nothing changed. The
+// branch is named <GITHUB_PR_BRANCH_PREFIX><date>-<unix time> (default prefix "news/").
+// GITHUB_PR_TITLE and GITHUB_PR_BODY are templates where {date} and {count} are
+// replaced; the title defaults to the commit message.
+func (u *GitHubUploader) OpenPullRequest(ctx context.Context, files map[string][]byte, commitMessage, date string) (string, error) {
+	baseRef, err := BranchRef(u.Branch)
+	if err != nil {
+		return "", err
+	}
+	base, _, err := u.Client.Git.GetRef(ctx, u.Owner, u.Repo, baseRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to get HEAD reference: %v", err)
+	}
+
+	prefix, ok := os.LookupEnv("GITHUB_PR_BRANCH_PREFIX")
+	if !ok {
+		prefix = "news/"
+	}
+	branch := fmt.Sprintf("%s%s-%d", prefix, date, time.Now().Unix())
+	_, _, err = u.Client.Git.CreateRef(ctx, u.Owner, u.Repo, &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: base.Object.SHA},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get HEAD reference: %v", err)
+		return "", fmt.Errorf("failed to create branch %s: %v", branch, err)
+	}
+
+	opened := false
+	defer func() {
+		// 변경 사항이 없거나 중간에 실패해 PR 이 열리지 않으면 만든 브랜치를 정리
+		if !opened {
+			if _, err := u.Client.Git.DeleteRef(ctx, u.Owner, u.Repo, "heads/"+branch); err != nil {
+				log.Printf("failed to delete branch %s: %v", branch, err)
+			}
+		}
+	}()
+
+	head := *u
+	head.Branch = branch
+	if err := head.UploadFiles(ctx, files, commitMessage); err != nil {
+		return "", err
 	}
 
+	template := func(value, fallback string) string {
+		if value == "" {
+			value = fallback
+		}
+		return strings.NewReplacer("{date}", date, "{count}", strconv.Itoa(len(files))).Replace(value)
+	}
+	pr, _, err := u.Client.PullRequests.Create(ctx, u.Owner, u.Repo, &github.NewPullRequest{
+		Title: github.String(template(os.Getenv("GITHUB_PR_TITLE"), commitMessage)),
+		Head:  github.String(branch),
+		Base:  github.String(strings.TrimPrefix(baseRef, "heads/")),
+		Body:  github.String(template(os.Getenv("GITHUB_PR_BODY"), "{date} 기사 {count}개")),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create pull request: %v", err)
+	}
+	opened = true
+	log.Printf("Opened pull request: %s", pr.GetHTMLURL())
+	return pr.GetHTMLURL(), nil
+}
+
+// commitAuthor returns the author and committer of the upload commits from
+// GIT_AUTHOR_NAME and GIT_AUTHOR_EMAIL, dated now, or nil to keep the token owner.
+// Both must be set; GitHub rejects an author without a name or email.
+func commitAuthor() *github.CommitAuthor {
+	name := os.Getenv("GIT_AUTHOR_NAME")
+	email := os.Getenv("GIT_AUTHOR_EMAIL")
+	if name == "" && email == "" {
+		return nil
+	}
+	if name == "" || email == "" {
+		log.Printf("GIT_AUTHOR_NAME and GIT_AUTHOR_EMAIL must both be set. Committing as the token owner")
+		return nil
+	}
+	now := time.Now()
+	return &github.CommitAuthor{
+		Name:  github.String(name),
+		Email: github.String(email),
+		Date:  &now,
+	}
+}
+
+// ErrNoChanges is returned when every file already has identical content on the branch,
+// in which case no commit is created.
+var ErrNoChanges = errors.New("no changes to commit")
+
+// BlobSHA returns the git blob SHA of content, as listed in tree entries.
+func BlobSHA(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// refConflictError is returned by commitFiles when UpdateRef rejects the commit
+// because the branch no longer points at its parent.
+type refConflictError struct {
+	err error
+}
+
+func (e *refConflictError) Error() string {
+	return fmt.Sprintf("failed to update HEAD reference: %v", e.err)
+}
+
+// commitFiles builds a tree and commit on the current HEAD of branchRef and moves the
+// branch to it, returning the SHA it built on.
+func (u *GitHubUploader) commitFiles(ctx context.Context, branchRef string, files map[string][]byte, commitMessage string) (string, error) {
+	// Get the reference to the HEAD of the target branch (e.g., main)
+	ref, _, err := u.Client.Git.GetRef(ctx, u.Owner, u.Repo, branchRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to get HEAD reference: %v", err)
+	}
+	base := ref.Object.GetSHA()
+
 	// Get the current tree of the default branch
-	baseTree, _, err := u.Client.Git.GetTree(ctx, u.Owner, u.Repo, *ref.Object.SHA, true)
+	baseTree, _, err := u.Client.Git.GetTree(ctx, u.Owner, u.Repo, base, true)
 	if err != nil {
-		return fmt.Errorf("failed to get base tree: %v", err)
+		return base, fmt.Errorf("failed to get base tree: %v", err)
+	}
+
+	// 기존 트리와 내용이 같은 파일은 제외해 내용 없는 커밋을 만들지 않음
+	existing := make(map[string]string)
+	for _, entry := range baseTree.Entries {
+		if entry.GetType() == "blob" {
+			existing[entry.GetPath()] = entry.GetSHA()
+		}
 	}
 
 	// Create a list of tree entries for the new files
 	var entries []*github.TreeEntry
 	for filePath, content := range files {
+		if sha, ok := existing[filePath]; ok && sha == BlobSHA(content) {
+			continue
+		}
 		entries = append(entries, &github.TreeEntry{
 			Path:    github.String(filePath),
 			Type:    github.String("blob"),
@@ -109,31 +308,85 @@ func (u *GitHubUploader) UploadFiles(ctx context.Context, files map[string][]byt
 		})
 	}
 
+	if len(entries) == 0 {
+		return base, ErrNoChanges
+	}
+	log.Printf("Committing %d of %d files (%d unchanged)", len(entries), len(files), len(files)-len(entries))
+
 	// Create a new tree based on the current tree
 	newTree, _, err := u.Client.Git.CreateTree(ctx, u.Owner, u.Repo, *baseTree.SHA, entries)
 	if err != nil {
-		return fmt.Errorf("failed to create tree: %v", err)
+		return base, fmt.Errorf("failed to create tree: %v", err)
+	}
+
+	// 커밋 전에 트리를 검증해 일부 파일이 빠진 커밋이 브랜치에 올라가지 않도록 함
+	if os.Getenv("VERIFY_COMMIT") == "true" {
+		if err := u.VerifyTree(ctx, newTree.GetSHA(), files); err != nil {
+			return base, err
+		}
 	}
 
 	// Create a new commit
 	newCommit := &github.Commit{
 		Message: github.String(commitMessage),
 		Tree:    newTree,
-		Parents: []*github.Commit{{SHA: ref.Object.SHA}},
+		Parents: []*github.Commit{{SHA: github.String(base)}},
+	}
+	if author := commitAuthor(); author != nil {
+		newCommit.Author = author
+		newCommit.Committer = author
 	}
 	commit, _, err := u.Client.Git.CreateCommit(ctx, u.Owner, u.Repo, newCommit)
 	if err != nil {
-		return fmt.Errorf("failed to create commit: %v", err)
+		return base, fmt.Errorf("failed to create commit: %v", err)
 	}
 
 	// Update the reference to point to the new commit
 	ref.Object.SHA = commit.SHA
-	_, _, err = u.Client.Git.UpdateRef(ctx, u.Owner, u.Repo, ref, false)
+	_, resp, err := u.Client.Git.UpdateRef(ctx, u.Owner, u.Repo, ref, false)
 	if err != nil {
-		return fmt.Errorf("failed to update HEAD reference: %v", err)
+		// 그 사이 다른 커밋이 올라와 fast-forward 가 불가능한 경우
+		if resp != nil && resp.StatusCode == http.StatusUnprocessableEntity {
+			return base, &refConflictError{err: err}
+		}
+		return base, fmt.Errorf("failed to update HEAD reference: %v", err)
 	}
 
 	log.Printf("Successfully created commit: %s", *commit.SHA)
+	return base, nil
+}
+
+// VerifyTree fetches the tree with the given SHA and checks that every file is
+// present as a blob with the expected size.
+func (u *GitHubUploader) VerifyTree(ctx context.Context, sha string, files map[string][]byte) error {
+	tree, _, err := u.Client.Git.GetTree(ctx, u.Owner, u.Repo, sha, true)
+	if err != nil {
+		return fmt.Errorf("failed to get tree for verification: %v", err)
+	}
+	if tree.GetTruncated() {
+		return fmt.Errorf("tree %s is too large to verify", sha)
+	}
+
+	sizes := make(map[string]int)
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			sizes[entry.GetPath()] = entry.GetSize()
+		}
+	}
+
+	var problems []string
+	for filePath, content := range files {
+		size, ok := sizes[filePath]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: missing", filePath))
+		} else if size != len(content) {
+			problems = append(problems, fmt.Sprintf("%s: size %d, expected %d", filePath, size, len(content)))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("tree verification failed: %s", strings.Join(problems, "; "))
+	}
+	log.Printf("Verified %d files in tree %s", len(files), sha)
 	return nil
 }
 
@@ -176,17 +429,144 @@ func (u *GitHubUploader) UploadFile(ctx context.Context, path string, content []
 	return nil
 }
 
+// FetchGitHubToken reads the GitHub token from Secrets Manager in secrets.Region. The
+// secret holds either the token itself or a JSON object with a TOKEN_GITHUB key.
+func FetchGitHubToken(ctx context.Context, secretARN string) (string, error) {
+	region, err := secrets.Region()
+	if err != nil {
+		return "", err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	client := secretsmanager.NewFromConfig(cfg)
+	output, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretARN),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret value: %v", err)
+	}
+
+	token := aws.ToString(output.SecretString)
+	var fields map[string]string
+	if json.Unmarshal([]byte(token), &fields) == nil {
+		token = fields["TOKEN_GITHUB"]
+	}
+	if token == "" {
+		return "", fmt.Errorf("secret %s does not contain a GitHub token", secretARN)
+	}
+	return token, nil
+}
+
+// GitHubPath maps an S3 key under prefix to its path in the repository,
+// preserving nested category folders:
+//
+//	news/2025-01-05/politics_0.md          -> 2025-01-05/politics_0.md
+//	news/2025-01-05/politics/politics_0.md -> 2025-01-05/politics/politics_0.md
+//
+// Compressed keys (.md.gz) map to the plain .md path since DownloadFile decompresses them.
+// Keys outside the prefix and folder placeholder keys (ending in "/") are skipped.
+func GitHubPath(date, prefix, key string) (string, bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	rel := strings.TrimSuffix(strings.TrimPrefix(key, prefix), ".gz")
+	if rel == "" || strings.HasSuffix(rel, "/") {
+		return "", false
+	}
+	return path.Join(date, rel), true
+}
+
+// DownloadAll downloads the keys under prefix with at most concurrency downloads at
+// once, keyed by their GitHubPath. A failed download is logged and skipped.
+func DownloadAll(ctx context.Context, downloader *S3Downloader, date, prefix string, keys []string, concurrency int) map[string][]byte {
+	fileContents := make(map[string][]byte)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, fileKey := range keys {
+		githubFilePath, ok := GitHubPath(date, prefix, fileKey)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(fileKey, githubFilePath string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fileContent, err := downloader.DownloadFile(ctx, fileKey)
+			if err != nil {
+				log.Printf("failed to download file %s: %v", fileKey, err)
+				return
+			}
+
+			mu.Lock()
+			fileContents[githubFilePath] = fileContent
+			mu.Unlock()
+		}(fileKey, githubFilePath)
+	}
+	wg.Wait()
+	return fileContents
+}
+
+// RequestDate returns the YYYY-MM-DD date from the "date" query-string parameter
+// for backfills, or today's date when it is absent or invalid.
+func RequestDate(request events.APIGatewayProxyRequest) string {
+	today := time.Now().Format("2006-01-02")
+	date := request.QueryStringParameters["date"]
+	if date == "" {
+		return today
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		log.Printf("Invalid date %q. Falling back to %s", date, today)
+		return today
+	}
+	return date
+}
+
 func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// 콜드 스타트에 불러오지 못했으면 다시 시도
+	if err := secrets.Load(ctx); err != nil {
+		log.Printf("failed to load secrets: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to load secrets: %v", err)}),
+		}, nil
+	}
+
+	if !model.VerifySignature(request.Headers, []byte(request.Body), secrets.Get) {
+		log.Printf("Rejected request with invalid signature")
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusUnauthorized,
+			Body:       `{"error": "Invalid signature"}`,
+		}, nil
+	}
 
 	// 1. 환경 변수 불러오기
-	awsRegion := "ap-northeast-2"
+	awsRegion, err := model.Region("S3_REGION")
+	if err != nil {
+		log.Printf("failed to resolve S3 region: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to resolve S3 region: %v", err)}),
+		}, nil
+	}
 	bucketName := os.Getenv("S3_BUCKET_NAME")
-	githubToken := os.Getenv("TOKEN_GITHUB")
+	githubToken := secrets.Get("TOKEN_GITHUB")
+	tokenSecretARN := os.Getenv("GITHUB_TOKEN_SECRET_ARN")
 	owner := os.Getenv("OWNER_GITHUB")
 	repo := os.Getenv("REPO_GITHUB")
+	branch, ok := os.LookupEnv("GITHUB_BRANCH")
+	if !ok {
+		branch = "main"
+	}
 
 	// 환경 변수 검증
-	if awsRegion == "" || bucketName == "" || githubToken == "" || owner == "" || repo == "" {
+	if awsRegion == "" || bucketName == "" || (githubToken == "" && tokenSecretARN == "") || owner == "" || repo == "" {
 		log.Fatalf("One or more required environment variables are missing.")
 	}
 
@@ -198,6 +578,18 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		log.Fatalf("failed to load AWS config: %v", err)
 	}
 
+	// Secrets Manager 가 설정되어 있으면 환경 변수 대신 토큰을 가져와 이번 호출 동안 사용
+	if tokenSecretARN != "" {
+		githubToken, err = FetchGitHubToken(ctx, tokenSecretARN)
+		if err != nil {
+			log.Printf("failed to fetch GitHub token: %v", err)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to fetch GitHub token: %v", err)}),
+			}, nil
+		}
+	}
+
 	s3Client := s3.NewFromConfig(cfg)
 	downloader := S3Downloader{
 		Client:     s3Client,
@@ -214,10 +606,18 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		Client: githubClient,
 		Owner:  owner,
 		Repo:   repo,
+		Branch: branch,
+	}
+	if _, err := BranchRef(branch); err != nil {
+		log.Printf("Invalid GITHUB_BRANCH: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Invalid GITHUB_BRANCH: %v", err)}),
+		}, nil
 	}
 
 	// 3. S3에서 파일 목록 가져오기
-	today := time.Now().Format("2006-01-02")
+	today := RequestDate(request)
 	prefix := fmt.Sprintf("news/%s/", today)
 	files, err := downloader.ListFiles(ctx, prefix)
 	if err != nil {
@@ -225,26 +625,56 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	}
 
 	// 4. 모든 파일 다운로드 및 GitHub 업로드 준비
-	fileContents := make(map[string][]byte)
-	for _, fileKey := range files {
-		fileContent, err := downloader.DownloadFile(ctx, fileKey)
-		if err != nil {
-			log.Printf("failed to download file %s: %v", fileKey, err)
-			continue
+	concurrency := 5
+	if value := os.Getenv("DOWNLOAD_CONCURRENCY"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			concurrency = n
+		} else {
+			log.Printf("Invalid DOWNLOAD_CONCURRENCY %q. Falling back to 5", value)
 		}
-
-		githubFilePath := path.Join(today, strings.TrimPrefix(fileKey, prefix))
-		fileContents[githubFilePath] = fileContent
 	}
+	fileContents := DownloadAll(ctx, &downloader, today, prefix, files, concurrency)
 
-	// 5. 한 번의 커밋으로 모든 파일 업로드
+	// 5. 한 번의 커밋으로 모든 파일 업로드, pr 모드에서는 새 브랜치에 커밋하고 PR 생성
+	if os.Getenv("GITHUB_MODE") == "pr" {
+		if len(fileContents) == 0 {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"message": "No files to upload"}`,
+			}, nil
+		}
+		url, err := uploader.OpenPullRequest(ctx, fileContents, fmt.Sprintf("Add: 오늘의 기사 추가(%s)", today), today)
+		if errors.Is(err, ErrNoChanges) {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"message": "No changes"}`,
+			}, nil
+		}
+		if err != nil {
+			log.Printf("failed to open pull request: %v", err)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to open pull request: %v", err)}),
+			}, nil
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       model.JSONBody(map[string]interface{}{"message": "Pull request opened", "url": url}),
+		}, nil
+	}
 	if len(fileContents) > 0 {
 		err = uploader.UploadFiles(ctx, fileContents, fmt.Sprintf("Add: 오늘의 기사 추가(%s)", today))
+		if errors.Is(err, ErrNoChanges) {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"message": "No changes"}`,
+			}, nil
+		}
 		if err != nil {
 			log.Printf("failed to upload files to GitHub: %v", err)
 			return events.APIGatewayProxyResponse{
 				StatusCode: http.StatusInternalServerError,
-				Body:       fmt.Sprintf(`{"error": "Failed to upload files: %v"}`, err),
+				Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to upload files: %v", err)}),
 			}, nil
 		}
 	}
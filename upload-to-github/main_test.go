@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-github/v45/github"
+)
+
+// fakeBlob is a tree entry of fakeGitHub.
+type fakeBlob struct {
+	sha  string
+	size int
+}
+
+// fakeGitHub is an in-memory GitHub API serving the git data, ref and pull request
+// endpoints GitHubUploader uses for the repository owner/repo.
+type fakeGitHub struct {
+	mu      sync.Mutex
+	refs    map[string]string              // "heads/<branch>" -> commit SHA
+	commits map[string]string              // commit SHA -> tree SHA
+	trees   map[string]map[string]fakeBlob // tree SHA -> path -> blob
+	next    int
+
+	createdTrees [][]string // paths of the entries sent to each CreateTree
+	createdRefs  []string
+	deletedRefs  []string
+	pulls        []github.NewPullRequest
+	// conflicts is the number of UpdateRef calls rejected with 422 after another
+	// commit is pushed to the branch, as when a concurrent upload wins the race.
+	conflicts int
+	// raced holds the commits pushed by the rejected UpdateRef calls.
+	raced []string
+	// fail makes the request whose "METHOD path-suffix" matches fail with 500.
+	fail string
+}
+
+// newFakeGitHub starts a fakeGitHub whose branch main holds files and returns it with
+// an uploader pointing at it.
+func newFakeGitHub(t *testing.T, files map[string][]byte) (*fakeGitHub, *GitHubUploader) {
+	t.Helper()
+	fake := &fakeGitHub{
+		refs:    make(map[string]string),
+		commits: make(map[string]string),
+		trees:   make(map[string]map[string]fakeBlob),
+	}
+	tree := make(map[string]fakeBlob)
+	for path, content := range files {
+		tree[path] = fakeBlob{sha: BlobSHA(content), size: len(content)}
+	}
+	fake.refs["heads/main"] = fake.commit(fake.store(tree))
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return fake, &GitHubUploader{Client: client, Owner: "owner", Repo: "repo", Branch: "main"}
+}
+
+// store saves tree and returns its SHA.
+func (f *fakeGitHub) store(tree map[string]fakeBlob) string {
+	f.next++
+	sha := fmt.Sprintf("tree%d", f.next)
+	f.trees[sha] = tree
+	return sha
+}
+
+// commit saves a commit of the tree and returns its SHA.
+func (f *fakeGitHub) commit(tree string) string {
+	f.next++
+	sha := fmt.Sprintf("commit%d", f.next)
+	f.commits[sha] = tree
+	return sha
+}
+
+// files returns the blob SHAs on branch by path.
+func (f *fakeGitHub) files(branch string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	files := make(map[string]string)
+	for path, blob := range f.trees[f.commits[f.refs["heads/"+branch]]] {
+		files[path] = blob.sha
+	}
+	return files
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/repos/owner/repo/")
+	if f.fail != "" && strings.HasSuffix(r.Method+" "+path, f.fail) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	reference := func(ref string) map[string]interface{} {
+		return map[string]interface{}{"ref": "refs/" + ref, "object": map[string]string{"type": "commit", "sha": f.refs[ref]}}
+	}
+	var body struct {
+		Ref      string `json:"ref"`
+		SHA      string `json:"sha"`
+		BaseTree string `json:"base_tree"`
+		Tree     json.RawMessage
+		Parents  []string
+	}
+	raw, _ := io.ReadAll(r.Body)
+	json.Unmarshal(raw, &body)
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "git/ref/"):
+		ref := strings.TrimPrefix(path, "git/ref/")
+		if _, ok := f.refs[ref]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(reference(ref))
+	case r.Method == http.MethodPost && path == "git/refs":
+		ref := strings.TrimPrefix(body.Ref, "refs/")
+		f.refs[ref] = body.SHA
+		f.createdRefs = append(f.createdRefs, ref)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(reference(ref))
+	case r.Method == http.MethodPatch && strings.HasPrefix(path, "git/refs/"):
+		ref := strings.TrimPrefix(path, "git/refs/")
+		if f.conflicts > 0 {
+			f.conflicts--
+			f.refs[ref] = f.commit(f.commits[f.refs[ref]])
+			f.raced = append(f.raced, f.refs[ref])
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Update is not a fast forward"})
+			return
+		}
+		f.refs[ref] = body.SHA
+		json.NewEncoder(w).Encode(reference(ref))
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "git/refs/"):
+		ref := strings.TrimPrefix(path, "git/refs/")
+		delete(f.refs, ref)
+		f.deletedRefs = append(f.deletedRefs, ref)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "git/trees/"):
+		sha := strings.TrimPrefix(path, "git/trees/")
+		if tree, ok := f.commits[sha]; ok {
+			sha = tree
+		}
+		var entries []map[string]interface{}
+		for path, blob := range f.trees[sha] {
+			entries = append(entries, map[string]interface{}{"path": path, "type": "blob", "sha": blob.sha, "size": blob.size})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"sha": sha, "tree": entries})
+	case r.Method == http.MethodPost && path == "git/trees":
+		var entries []struct{ Path, Content string }
+		json.Unmarshal(body.Tree, &entries)
+		tree := make(map[string]fakeBlob)
+		for path, blob := range f.trees[body.BaseTree] {
+			tree[path] = blob
+		}
+		var paths []string
+		for _, entry := range entries {
+			tree[entry.Path] = fakeBlob{sha: BlobSHA([]byte(entry.Content)), size: len(entry.Content)}
+			paths = append(paths, entry.Path)
+		}
+		f.createdTrees = append(f.createdTrees, paths)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"sha": f.store(tree)})
+	case r.Method == http.MethodPost && path == "git/commits":
+		var tree string
+		json.Unmarshal(body.Tree, &tree)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"sha": f.commit(tree)})
+	case r.Method == http.MethodPost && path == "pulls":
+		var pull github.NewPullRequest
+		json.Unmarshal(raw, &pull)
+		f.pulls = append(f.pulls, pull)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"html_url": fmt.Sprintf("https://github.com/owner/repo/pull/%d", len(f.pulls))})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestFetchGitHubTokenUsesSecretsRegion(t *testing.T) {
+	var region string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Credential=<key>/<date>/<region>/secretsmanager/aws4_request
+		if _, credential, ok := strings.Cut(r.Header.Get("Authorization"), "Credential="); ok {
+			if scope := strings.Split(credential, "/"); len(scope) > 2 {
+				region = scope[2]
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]string{"Name": "github-token", "SecretString": `{"TOKEN_GITHUB": "ghp-from-secret"}`})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("SECRETS_REGION", "eu-west-1")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	token, err := FetchGitHubToken(context.Background(), "github-token")
+	if err != nil || token != "ghp-from-secret" {
+		t.Fatalf("FetchGitHubToken = (%q, %v), want the token of the secret", token, err)
+	}
+	if region != "eu-west-1" {
+		t.Errorf("Secrets Manager region = %q, want SECRETS_REGION", region)
+	}
+}
+
+func TestBlobSHA(t *testing.T) {
+	// git hash-object 로 계산한 값
+	tests := map[string]string{
+		"":        "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		"hello\n": "ce013625030ba8dba906f756967f9e9ca394464a",
+	}
+	for content, want := range tests {
+		if got := BlobSHA([]byte(content)); got != want {
+			t.Errorf("BlobSHA(%q) = %s, want %s", content, got, want)
+		}
+	}
+}
+
+func TestUploadFilesSkipsUnchangedFiles(t *testing.T) {
+	existing := map[string][]byte{
+		"news/2025-01-02/politics_0.md": []byte("# 정치"),
+		"news/2025-01-02/it_0.md":       []byte("# IT"),
+	}
+	fake, uploader := newFakeGitHub(t, existing)
+
+	err := uploader.UploadFiles(context.Background(), existing, "Add news")
+	if !errors.Is(err, ErrNoChanges) {
+		t.Fatalf("UploadFiles of unchanged files = %v, want ErrNoChanges", err)
+	}
+	if len(fake.createdTrees) != 0 {
+		t.Errorf("created trees %v for unchanged files", fake.createdTrees)
+	}
+
+	files := map[string][]byte{
+		"news/2025-01-02/politics_0.md": []byte("# 정치"),
+		"news/2025-01-02/it_0.md":       []byte("# IT 수정"),
+	}
+	if err := uploader.UploadFiles(context.Background(), files, "Add news"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.createdTrees) != 1 || strings.Join(fake.createdTrees[0], ",") != "news/2025-01-02/it_0.md" {
+		t.Errorf("created trees = %v, want only the changed file", fake.createdTrees)
+	}
+	for path, content := range files {
+		if got := fake.files("main")[path]; got != BlobSHA(content) {
+			t.Errorf("%s on main = %s, want %s", path, got, BlobSHA(content))
+		}
+	}
+}
+
+func TestBranchRef(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"main", "heads/main"},
+		{"news", "heads/news"},
+		{"refs/heads/news", "heads/news"},
+		{" refs/heads/news/daily ", "heads/news/daily"},
+	}
+	for _, tt := range tests {
+		got, err := BranchRef(tt.branch)
+		if err != nil || got != tt.want {
+			t.Errorf("BranchRef(%q) = (%q, %v), want %q", tt.branch, got, err, tt.want)
+		}
+	}
+	for _, branch := range []string{"", " ", "refs/heads/"} {
+		if got, err := BranchRef(branch); err == nil {
+			t.Errorf("BranchRef(%q) = %q, want an error", branch, got)
+		}
+	}
+}
+
+func TestCommitAuthorRequiresNameAndEmail(t *testing.T) {
+	tests := []struct {
+		name, email string
+		want        bool
+	}{
+		{"", "", false},
+		{"news-bot", "", false},
+		{"", "news-bot@example.com", false},
+		{"news-bot", "news-bot@example.com", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("GIT_AUTHOR_NAME", tt.name)
+		t.Setenv("GIT_AUTHOR_EMAIL", tt.email)
+		author := commitAuthor()
+		if (author != nil) != tt.want {
+			t.Errorf("commitAuthor() with name %q and email %q = %v, want author %v", tt.name, tt.email, author, tt.want)
+			continue
+		}
+		if author != nil && (author.GetName() != tt.name || author.GetEmail() != tt.email) {
+			t.Errorf("commitAuthor() = %s <%s>, want %s <%s>", author.GetName(), author.GetEmail(), tt.name, tt.email)
+		}
+	}
+}
+
+func TestUploadFilesToCustomBranch(t *testing.T) {
+	fake, uploader := newFakeGitHub(t, nil)
+	fake.refs["heads/news"] = fake.refs["heads/main"]
+	uploader.Branch = "refs/heads/news"
+
+	files := map[string][]byte{"news/2025-01-02/it_0.md": []byte("# IT")}
+	if err := uploader.UploadFiles(context.Background(), files, "Add news"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.files("news")["news/2025-01-02/it_0.md"]; got != BlobSHA(files["news/2025-01-02/it_0.md"]) {
+		t.Errorf("file on news = %q, want the uploaded blob", got)
+	}
+	if got := fake.files("main"); len(got) != 0 {
+		t.Errorf("main = %v, want it untouched", got)
+	}
+
+	uploader.Branch = ""
+	if err := uploader.UploadFiles(context.Background(), files, "Add news"); err == nil {
+		t.Error("UploadFiles with an empty branch succeeded")
+	}
+}
+
+func TestDownloadAllDownloadsEveryFile(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/news-bucket/")
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		// 앞의 key 일수록 늦게 끝나도록 해 완료 순서를 요청 순서와 반대로 만듦
+		var index int
+		fmt.Sscanf(key, "news/2025-01-02/politics_%d.md", &index)
+		time.Sleep(time.Duration(10-index) * 5 * time.Millisecond)
+		if key == "news/2025-01-02/broken.md" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, key)
+	}))
+	defer server.Close()
+	downloader := &S3Downloader{
+		Client: s3.New(s3.Options{
+			BaseEndpoint:     aws.String(server.URL),
+			UsePathStyle:     true,
+			Region:           "ap-northeast-2",
+			Credentials:      aws.AnonymousCredentials{},
+			RetryMaxAttempts: 1,
+		}),
+		BucketName: "news-bucket",
+	}
+
+	var keys []string
+	want := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("news/2025-01-02/politics_%d.md", i)
+		keys = append(keys, key)
+		want[fmt.Sprintf("2025-01-02/politics_%d.md", i)] = key
+	}
+	keys = append(keys, "news/2025-01-02/broken.md", "news/2025-01-02/politics/")
+
+	files := DownloadAll(context.Background(), downloader, "2025-01-02", "news/2025-01-02/", keys, 3)
+	if len(files) != len(want) {
+		t.Errorf("downloaded %d files, want %d", len(files), len(want))
+	}
+	for path, key := range want {
+		if got := string(files[path]); got != key {
+			t.Errorf("%s = %q, want the content of %s", path, got, key)
+		}
+	}
+	if maxInFlight > 3 {
+		t.Errorf("%d downloads ran at once, want at most 3", maxInFlight)
+	}
+}
+
+func TestGitHubPathMatchesUploadKeys(t *testing.T) {
+	// upload-to-s3 의 ObjectKey 형식(news/<date>/<name>.md)과 카테고리 폴더 형식
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"news/2025-01-02/politics_0.md", "2025-01-02/politics_0.md", true},
+		{"news/2025-01-02/politics_0.md.gz", "2025-01-02/politics_0.md", true},
+		{"news/2025-01-02/politics/politics_0.md", "2025-01-02/politics/politics_0.md", true},
+		{"news/2025-01-02/politics/", "", false},
+		{"news/2025-01-01/politics_0.md", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := GitHubPath("2025-01-02", "news/2025-01-02/", tt.key)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("GitHubPath(%q) = (%q, %v), want (%q, %v)", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUploadFilesLogsNewHeadOnConflict(t *testing.T) {
+	fake, uploader := newFakeGitHub(t, nil)
+	fake.conflicts = 1
+	base := fake.refs["heads/main"]
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	files := map[string][]byte{"news/2025-01-02/it_0.md": []byte("# IT")}
+	if err := uploader.UploadFiles(context.Background(), files, "Add news"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.raced) != 1 {
+		t.Fatalf("raced commits = %v, want 1", fake.raced)
+	}
+	want := fmt.Sprintf("Branch moved from %s to %s", base, fake.raced[0])
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("logs = %q, want %q", logs.String(), want)
+	}
+	if len(fake.createdTrees) != 2 {
+		t.Errorf("created %d trees, want the commit rebuilt once", len(fake.createdTrees))
+	}
+}
+
+func TestOpenPullRequestDeletesBranchWhenNoPullRequestIsOpened(t *testing.T) {
+	t.Setenv("GITHUB_PR_BRANCH_PREFIX", "news/")
+	existing := map[string][]byte{"2025-01-02/it_0.md": []byte("# IT")}
+	changed := map[string][]byte{"2025-01-02/it_0.md": []byte("# IT 수정")}
+	tests := []struct {
+		name    string
+		files   map[string][]byte
+		fail    string
+		wantErr error
+	}{
+		{"no changes", existing, "", ErrNoChanges},
+		{"tree creation fails", changed, "POST git/trees", nil},
+		{"pull request creation fails", changed, "POST pulls", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, uploader := newFakeGitHub(t, existing)
+			fake.fail = tt.fail
+
+			url, err := uploader.OpenPullRequest(context.Background(), tt.files, "Add news", "2025-01-02")
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("OpenPullRequest = (%q, %v), want an error", url, err)
+			}
+			if len(fake.createdRefs) != 1 || !strings.HasPrefix(fake.createdRefs[0], "heads/news/2025-01-02-") {
+				t.Fatalf("created refs = %v, want one pull request branch", fake.createdRefs)
+			}
+			if len(fake.deletedRefs) != 1 || fake.deletedRefs[0] != fake.createdRefs[0] {
+				t.Errorf("deleted refs = %v, want %v", fake.deletedRefs, fake.createdRefs)
+			}
+		})
+	}
+}
+
+func TestOpenPullRequestKeepsBranchOfOpenedPullRequest(t *testing.T) {
+	fake, uploader := newFakeGitHub(t, nil)
+	files := map[string][]byte{"2025-01-02/it_0.md": []byte("# IT")}
+
+	url, err := uploader.OpenPullRequest(context.Background(), files, "Add news", "2025-01-02")
+	if err != nil || url == "" {
+		t.Fatalf("OpenPullRequest = (%q, %v), want a pull request URL", url, err)
+	}
+	if len(fake.deletedRefs) != 0 {
+		t.Errorf("deleted refs %v of an opened pull request", fake.deletedRefs)
+	}
+	if len(fake.pulls) != 1 || fake.pulls[0].GetBase() != "main" || "heads/"+fake.pulls[0].GetHead() != fake.createdRefs[0] {
+		t.Errorf("pull requests = %+v, want one from %v into main", fake.pulls, fake.createdRefs)
+	}
+}
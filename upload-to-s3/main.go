@@ -1,19 +1,32 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/Sniij/mircro-services-golang/model"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/joho/godotenv"
 )
 
@@ -32,23 +45,466 @@ func init() {
 	}
 }
 
-// Upload uploads a file to S3
-func (u *S3Uploader) Upload(ctx context.Context, key string, content []byte) error {
-	_, err := u.Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(u.BucketName),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(content),
-		ContentType: aws.String("text/markdown"), // 마크다운 파일 MIME 타입
+// ErrWrittenByRun is returned by Upload when key was already written with different
+// content by the run in the run-id metadata, so a second article of the same run
+// cannot overwrite the first.
+var ErrWrittenByRun = errors.New("file already written in this run")
+
+// Upload uploads a file to S3 with the given object metadata, see ArticleMetadata.
+// Keys ending in .gz are stored gzip-compressed with Content-Encoding gzip.
+// The SHA-256 of content is stored as the contenthash metadata, and the upload is
+// skipped (skipped is true) when key already holds the same hash. A skipped object
+// from another run still gets the new metadata, so the next upload sees this run.
+// A single HEAD answers both the run and the hash check; a retry of an upload that
+// already landed in this run is skipped rather than rejected.
+// The PUT is conditional on the object seen by the HEAD (If-None-Match: * when there
+// was none), so a write racing between the two is detected and checked again.
+func (u *S3Uploader) Upload(ctx context.Context, key string, content []byte, metadata map[string]string) (bool, error) {
+	defer model.LogTiming("PutObject", key, time.Now())
+
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	newMetadata := map[string]string{"contenthash": hash}
+	for name, value := range metadata {
+		newMetadata[name] = value
+	}
+	runID := newMetadata["run-id"]
+
+	body := content
+	if strings.HasSuffix(key, ".gz") {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(content); err != nil {
+			return false, fmt.Errorf("failed to compress file: %v", err)
+		}
+		if err := gz.Close(); err != nil {
+			return false, fmt.Errorf("failed to compress file: %v", err)
+		}
+		body = buf.Bytes()
+	}
+
+	for attempt := 1; ; attempt++ {
+		existing, err := u.head(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		if existing != nil {
+			if existing.Metadata["contenthash"] == hash {
+				// 같은 날 재실행이거나 이미 올라간 업로드의 재시도: PUT 생략
+				log.Printf("Skipping unchanged file %s", key)
+				// 내용은 같아도 run-id 가 다르면 메타데이터만 교체해 다음 업로드가 이번 실행을 인식하도록 함
+				if existing.Metadata["run-id"] != runID {
+					if err := u.replaceMetadata(ctx, key, newMetadata); err != nil {
+						return true, err
+					}
+				}
+				return true, nil
+			}
+			if runID != "" && existing.Metadata["run-id"] == runID {
+				return false, ErrWrittenByRun
+			}
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket:      aws.String(u.BucketName),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String("text/markdown"), // 마크다운 파일 MIME 타입
+			Metadata:    newMetadata,
+		}
+		if strings.HasSuffix(key, ".gz") {
+			input.ContentEncoding = aws.String("gzip")
+		}
+		// HEAD 이후 다른 요청이 먼저 쓴 경우 덮어쓰지 않도록 조건부 PUT
+		if existing == nil {
+			input.IfNoneMatch = aws.String("*")
+		} else {
+			input.IfMatch = existing.ETag
+		}
+		input.ServerSideEncryption, input.SSEKMSKeyId = encryption()
+		_, err = u.Client.PutObject(ctx, input)
+		if preconditionFailed(err) && attempt < 2 {
+			log.Printf("File %s changed during upload, checking again", key)
+			continue
+		}
+		return false, err
+	}
+}
+
+// preconditionFailed reports whether err is S3 rejecting a conditional PUT because
+// the object changed since it was read.
+func preconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+	return false
+}
+
+// head returns the metadata of key, or nil when key does not exist.
+func (u *S3Uploader) head(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+	output, err := u.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(u.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to head object: %v", err)
+	}
+	return output, nil
+}
+
+// replaceMetadata copies key onto itself with metadata, keeping its content.
+func (u *S3Uploader) replaceMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(u.BucketName),
+		Key:               aws.String(key),
+		CopySource:        aws.String(u.BucketName + "/" + (&url.URL{Path: key}).EscapedPath()),
+		ContentType:       aws.String("text/markdown"),
+		Metadata:          metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	}
+	if strings.HasSuffix(key, ".gz") {
+		input.ContentEncoding = aws.String("gzip")
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = encryption()
+	if _, err := u.Client.CopyObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to update metadata: %v", err)
+	}
+	return nil
+}
+
+// encryption returns the server-side encryption to request for PutObject and
+// CopyObject: none unless S3_SSE=true, then SSE-KMS with S3_SSE_KMS_KEY_ID when
+// set, AES256 otherwise.
+func encryption() (types.ServerSideEncryption, *string) {
+	if os.Getenv("S3_SSE") != "true" {
+		return "", nil
+	}
+	if keyID := os.Getenv("S3_SSE_KMS_KEY_ID"); keyID != "" {
+		return types.ServerSideEncryptionAwsKms, aws.String(keyID)
+	}
+	return types.ServerSideEncryptionAes256, nil
+}
+
+// ArticleMetadata returns the object metadata of an uploaded article: its category
+// (the x-category-sniij header), the upload time in RFC3339, the source identifier
+// from the optional x-source-sniij header, the run-id Upload checks against and the
+// correlation-id from the x-correlation-id header. Empty values are left out.
+func ArticleMetadata(category, source, runID, correlationID string, uploaded time.Time) map[string]string {
+	metadata := map[string]string{
+		"category":    category,
+		"uploaded-at": uploaded.Format(time.RFC3339),
+	}
+	if source != "" {
+		metadata["source"] = source
+	}
+	if runID != "" {
+		metadata["run-id"] = runID
+	}
+	if correlationID != "" {
+		metadata["correlation-id"] = correlationID
+	}
+	return metadata
+}
+
+// ObjectKey returns the S3 key for an article:
+//
+//	news/<date>/<name>.md
+//
+// name is the x-category-sniij header, used exactly once. auto-push sets it to
+// <category>_<index> (or <category>_<article ID> with FILENAME_BY_ID=true) and appends
+// a suffix when two articles map to the same name, so keys are unique within a run.
+// Path separators, whitespace and control characters in name become "-" so a header
+// cannot escape the day folder.
+// Re-runs on the same day reuse the key and overwrite the previous file.
+func ObjectKey(date time.Time, name string) string {
+	day := date.Format("2006-01-02")
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return '-'
+		}
+		return r
+	}, name)
+	return fmt.Sprintf("news/%s/%s.md", day, name)
+}
+
+// PresignGet generates a presigned GET URL for the given key
+func (u *S3Uploader) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(u.Client)
+	req, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(u.BucketName),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object: %v", err)
+	}
+	return req.URL, nil
+}
+
+// presignExpiry reads PRESIGN_TTL (e.g. "15m", "1h"), defaulting to 15 minutes.
+// A zero TTL disables presigning. PRESIGNED_URL_EXPIRY is still read when PRESIGN_TTL is unset.
+func presignExpiry() time.Duration {
+	name := "PRESIGN_TTL"
+	expiry := os.Getenv(name)
+	if expiry == "" {
+		name = "PRESIGNED_URL_EXPIRY"
+		expiry = os.Getenv(name)
+	}
+	if expiry == "" {
+		return 15 * time.Minute
+	}
+	if expiry == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(expiry)
+	if err != nil || d < 0 {
+		log.Printf("Invalid %s %q. Falling back to 15m", name, expiry)
+		return 15 * time.Minute
+	}
+	return d
+}
+
+// List returns every key under prefix
+func (u *S3Uploader) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(u.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(u.BucketName),
+		Prefix: aws.String(prefix),
 	})
-	return err
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files: %v", err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
+
+// Download reads the object at key
+func (u *S3Uploader) Download(ctx context.Context, key string) ([]byte, error) {
+	output, err := u.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(u.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %v", err)
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+// Delete removes keys, up to 1000 per request
+func (u *S3Uploader) Delete(ctx context.Context, keys []string) error {
+	for start := 0; start < len(keys); start += 1000 {
+		end := min(start+1000, len(keys))
+		var objects []types.ObjectIdentifier
+		for _, key := range keys[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+		output, err := u.Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(u.BucketName),
+			Delete: &types.Delete{Objects: objects},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete files: %v", err)
+		}
+		if len(output.Errors) > 0 {
+			return fmt.Errorf("failed to delete %d files, first: %s", len(output.Errors), aws.ToString(output.Errors[0].Key))
+		}
+	}
+	return nil
+}
+
+// ArchiveDay bundles the markdown files of day into archive/<date>.tar.gz and deletes
+// the originals. With dryRun it only returns the keys that would be archived.
+func ArchiveDay(ctx context.Context, uploader S3Uploader, day time.Time, dryRun bool) ([]string, error) {
+	date := day.Format("2006-01-02")
+	keys, err := uploader.List(ctx, fmt.Sprintf("news/%s/", date))
+	if err != nil {
+		return nil, err
+	}
+	if dryRun || len(keys) == 0 {
+		return keys, nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, key := range keys {
+		content, err := uploader.Download(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		header := &tar.Header{
+			Name:    key,
+			Mode:    0o644,
+			Size:    int64(len(content)),
+			ModTime: day,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("failed to write archive header: %v", err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, fmt.Errorf("failed to write archive: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close archive: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close archive: %v", err)
+	}
+
+	// 아카이브 업로드가 성공한 뒤에만 원본 삭제
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(uploader.BucketName),
+		Key:         aws.String(fmt.Sprintf("archive/%s.tar.gz", date)),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/gzip"),
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = encryption()
+	_, err = uploader.Client.PutObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload archive: %v", err)
+	}
+	if err := uploader.Delete(ctx, keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// handleArchive runs the ?mode=archive&date=YYYY-MM-DD maintenance path. Only days
+// older than ARCHIVE_RETENTION_DAYS (default 30) can be archived; dry_run=true lists
+// the files without changing anything.
+func handleArchive(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	date := request.QueryStringParameters["date"]
+	if !model.VerifySignature(request.Headers, []byte(date), os.Getenv) {
+		log.Printf("Rejected request with invalid signature")
+		return events.APIGatewayProxyResponse{
+			StatusCode: 401,
+			Body:       `{"error": "Invalid signature"}`,
+		}, nil
+	}
+
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       model.JSONBody(map[string]interface{}{"error": "Invalid date: " + date}),
+		}, nil
+	}
+
+	retention := 30
+	if value := os.Getenv("ARCHIVE_RETENTION_DAYS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			retention = n
+		} else {
+			log.Printf("Invalid ARCHIVE_RETENTION_DAYS %q. Falling back to 30", value)
+		}
+	}
+	if !day.Before(time.Now().AddDate(0, 0, -retention)) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("%s is within the %d day retention window", date, retention)}),
+		}, nil
+	}
+
+	region, err := model.Region("S3_REGION")
+	if err != nil {
+		log.Printf("failed to resolve S3 region: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to resolve S3 region: %v", err)}),
+		}, nil
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		log.Printf("failed to load AWS config: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to load AWS config: %v", err)}),
+		}, nil
+	}
+	uploader := S3Uploader{
+		Client:     s3.NewFromConfig(cfg),
+		BucketName: os.Getenv("S3_BUCKET_NAME"),
+	}
+
+	dryRun := request.QueryStringParameters["dry_run"] == "true"
+	keys, err := ArchiveDay(ctx, uploader, day, dryRun)
+	if err != nil {
+		log.Printf("failed to archive %s: %v", date, err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to archive %s: %v", date, err)}),
+		}, nil
+	}
+	log.Printf("Archive %s (dry run: %v): %d files", date, dryRun, len(keys))
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Body: model.JSONBody(map[string]interface{}{
+			"date":    date,
+			"dry_run": dryRun,
+			"files":   keys,
+		}),
+	}, nil
+}
+
+// newReplicaUploader returns an uploader for REPLICA_BUCKET_NAME in REPLICA_REGION
+// (default ap-northeast-2), or nil when no replica bucket is configured.
+// REPLICA_MODE=required fails the upload when the replica write fails; by default
+// the replica is best effort.
+func newReplicaUploader(ctx context.Context) (*S3Uploader, error) {
+	bucketName := os.Getenv("REPLICA_BUCKET_NAME")
+	if bucketName == "" {
+		return nil, nil
+	}
+	region := os.Getenv("REPLICA_REGION")
+	if region == "" {
+		region = model.DefaultRegion
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config for replica: %v", err)
+	}
+	return &S3Uploader{
+		Client:     s3.NewFromConfig(cfg),
+		BucketName: bucketName,
+	}, nil
 }
 
 // LambdaHandler handles the Lambda event
 func LambdaHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// auto-push 가 보낸 상관관계 ID 를 이번 호출의 모든 로그에 붙여 서비스 간 추적
+	if id := request.Headers["x-correlation-id"]; id != "" {
+		log.SetPrefix("correlation_id=" + id + " ")
+		defer log.SetPrefix("")
+	}
+	// 업로드와 별개로 명시적으로 호출하는 보관(아카이브) 작업
+	if request.QueryStringParameters["mode"] == "archive" {
+		return handleArchive(ctx, request)
+	}
 
-	category, exist := request.Headers["x-category-sniij"]
-	if !exist {
-		log.Fatalf("failed to get x-category-sniij")
+	category := request.Headers["x-category-sniij"]
+	if category == "" {
+		log.Printf("Missing x-category-sniij header")
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       `{"error": "Missing x-category-sniij header"}`,
+		}, nil
 	}
 	// 요청 본문 디코딩
 	var markdownContent []byte
@@ -68,6 +524,14 @@ func LambdaHandler(ctx context.Context, request events.APIGatewayProxyRequest) (
 		markdownContent = []byte(request.Body)
 	}
 
+	if !model.VerifySignature(request.Headers, markdownContent, os.Getenv) {
+		log.Printf("Rejected request with invalid signature")
+		return events.APIGatewayProxyResponse{
+			StatusCode: 401,
+			Body:       `{"error": "Invalid signature"}`,
+		}, nil
+	}
+
 	if len(markdownContent) == 0 {
 		log.Printf("Request body is empty")
 		return events.APIGatewayProxyResponse{
@@ -76,9 +540,21 @@ func LambdaHandler(ctx context.Context, request events.APIGatewayProxyRequest) (
 		}, nil
 	}
 	// S3 설정 초기화
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("ap-northeast-2"))
+	region, err := model.Region("S3_REGION")
 	if err != nil {
-		log.Fatalf("failed to load AWS config: %v", err)
+		log.Printf("failed to resolve S3 region: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to resolve S3 region: %v", err)}),
+		}, nil
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		log.Printf("failed to load AWS config: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to load AWS config: %v", err)}),
+		}, nil
 	}
 
 	s3Client := s3.NewFromConfig(cfg)
@@ -87,22 +563,77 @@ func LambdaHandler(ctx context.Context, request events.APIGatewayProxyRequest) (
 		BucketName: os.Getenv("S3_BUCKET_NAME"),
 	}
 
-	filename := fmt.Sprintf("news/%s/%s_%s.md", time.Now().Format("2006-01-02"), time.Now().Format("2006-01-02"), category)
+	filename := ObjectKey(time.Now(), category)
+	// 압축 저장 시 .md.gz 로 올리고 upload-to-github 에서 풀어서 커밋
+	if os.Getenv("COMPRESS_UPLOADS") == "true" {
+		filename += ".gz"
+	}
+
+	runID := request.Headers["x-run-id-sniij"]
 
 	// 파일 업로드
-	err = uploader.Upload(ctx, filename, markdownContent)
+	metadata := ArticleMetadata(category, request.Headers["x-source-sniij"], runID, request.Headers["x-correlation-id"], time.Now())
+	skipped, err := uploader.Upload(ctx, filename, markdownContent, metadata)
+	// 같은 실행에서 이미 쓴 key 라면 다른 기사를 덮어쓰지 않도록 거부
+	if errors.Is(err, ErrWrittenByRun) {
+		log.Printf("Duplicate key %s in run %s", filename, runID)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 409,
+			Body:       model.JSONBody(map[string]interface{}{"error": "File already written in this run", "filename": filename}),
+		}, nil
+	}
 	if err != nil {
 		log.Printf("failed to upload file: %v", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error": "Failed to upload file: %v"}`, err),
+			Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to upload file: %v", err)}),
 		}, nil
 	}
 
+	// 보조 버킷 복제: required 모드에서는 복제 실패를 업로드 실패로 처리
+	if replica, err := newReplicaUploader(ctx); err != nil || replica != nil {
+		if err == nil {
+			_, err = replica.Upload(ctx, filename, markdownContent, metadata)
+		}
+		if err != nil {
+			if os.Getenv("REPLICA_MODE") == "required" {
+				log.Printf("failed to replicate file: %v", err)
+				return events.APIGatewayProxyResponse{
+					StatusCode: 500,
+					Body:       model.JSONBody(map[string]interface{}{"error": fmt.Sprintf("Failed to replicate file: %v", err)}),
+				}, nil
+			}
+			log.Printf("failed to replicate file (best effort): %v", err)
+		}
+	}
+
+	// 다운스트림에서 S3 자격 증명 없이 받을 수 있도록 presigned URL 반환
+	// 업로드는 이미 끝났으므로 presign 실패 시 url 없이 성공 응답
+	if expiry := presignExpiry(); expiry > 0 {
+		url, err := uploader.PresignGet(ctx, filename, expiry)
+		if err != nil {
+			log.Printf("failed to presign file, omitting url: %v", err)
+		} else {
+			return events.APIGatewayProxyResponse{
+				StatusCode: 200,
+				Body: model.JSONBody(map[string]interface{}{
+					"message":  "File uploaded successfully",
+					"filename": filename,
+					"skipped":  skipped,
+					"url":      url,
+				}),
+			}, nil
+		}
+	}
+
 	// 성공 응답 반환
 	return events.APIGatewayProxyResponse{
 		StatusCode: 200,
-		Body:       fmt.Sprintf(`{"message": "File uploaded successfully", "filename": "%s"}`, filename),
+		Body: model.JSONBody(map[string]interface{}{
+			"message":  "File uploaded successfully",
+			"filename": filename,
+			"skipped":  skipped,
+		}),
 	}, nil
 }
 
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeObject is an object stored by fakeS3.
+type fakeObject struct {
+	body     []byte
+	metadata map[string]string
+	header   http.Header
+	etag     string
+}
+
+// fakeS3 is an in-memory S3 endpoint that serves the path-style HEAD, GET, PUT and
+// copy requests S3Uploader makes, including If-None-Match and If-Match on PUT.
+type fakeS3 struct {
+	URL      string
+	mu       sync.Mutex
+	objects  map[string]*fakeObject
+	heads    int
+	puts     int
+	copies   int
+	versions int
+	// failBucket makes every request to this bucket fail with 500.
+	failBucket string
+	// afterHead, when set, runs after every HEAD with the lock held, to simulate
+	// a write racing with the upload.
+	afterHead func(path string)
+}
+
+// newFakeS3 starts a fakeS3 and returns it with a client pointing at it.
+// AWS_ENDPOINT_URL_S3=fake.URL points the clients LambdaHandler creates at it.
+func newFakeS3(t *testing.T) (*fakeS3, *s3.Client) {
+	t.Helper()
+	fake := &fakeS3{objects: make(map[string]*fakeObject)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	fake.URL = server.URL
+	client := s3.New(s3.Options{
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Region:       "ap-northeast-2",
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	return fake, client
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if f.failBucket != "" && strings.HasPrefix(path, f.failBucket+"/") {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		if r.Method == http.MethodHead {
+			f.heads++
+			if f.afterHead != nil {
+				defer f.afterHead(path)
+			}
+		}
+		object, ok := f.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, value := range object.metadata {
+			w.Header().Set("x-amz-meta-"+name, value)
+		}
+		w.Header().Set("ETag", object.etag)
+		if r.Method == http.MethodGet {
+			w.Write(object.body)
+		}
+	case http.MethodPut:
+		metadata := make(map[string]string)
+		for name, values := range r.Header {
+			if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-meta-") {
+				metadata[strings.TrimPrefix(lower, "x-amz-meta-")] = values[0]
+			}
+		}
+		if source := r.Header.Get("x-amz-copy-source"); source != "" {
+			source, _ = url.PathUnescape(strings.TrimPrefix(source, "/"))
+			object, ok := f.objects[source]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			f.copies++
+			f.objects[path] = f.newObject(object.body, metadata, r.Header)
+			xml.NewEncoder(w).Encode(struct {
+				XMLName xml.Name `xml:"CopyObjectResult"`
+				ETag    string
+			}{ETag: f.objects[path].etag})
+			return
+		}
+		existing, ok := f.objects[path]
+		ifMatch := r.Header.Get("If-Match")
+		if r.Header.Get("If-None-Match") == "*" && ok || ifMatch != "" && (!ok || ifMatch != existing.etag) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.puts++
+		f.objects[path] = f.newObject(body, metadata, r.Header)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// newObject returns an object with a fresh ETag. The caller holds the lock.
+func (f *fakeS3) newObject(body []byte, metadata map[string]string, header http.Header) *fakeObject {
+	f.versions++
+	return &fakeObject{body: body, metadata: metadata, header: header.Clone(), etag: fmt.Sprintf(`"%d"`, f.versions)}
+}
+
+// object returns the object stored at bucket/key, or nil.
+func (f *fakeS3) object(bucket, key string) *fakeObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[bucket+"/"+key]
+}
+
+func TestUploadSkipsUnchangedContentButRecordsRun(t *testing.T) {
+	fake, client := newFakeS3(t)
+	uploader := S3Uploader{Client: client, BucketName: "news"}
+	ctx := context.Background()
+	key := "news/2025-01-02/politics_0.md"
+	content := []byte("# 제목")
+
+	skipped, err := uploader.Upload(ctx, key, content, map[string]string{"run-id": "run1"})
+	if err != nil || skipped {
+		t.Fatalf("first Upload = (%v, %v), want (false, nil)", skipped, err)
+	}
+	skipped, err = uploader.Upload(ctx, key, content, map[string]string{"run-id": "run2"})
+	if err != nil || !skipped {
+		t.Fatalf("second Upload = (%v, %v), want (true, nil)", skipped, err)
+	}
+	// 업로드마다 HEAD 한 번으로 실행과 내용 확인
+	if fake.heads != 2 || fake.puts != 1 || fake.copies != 1 {
+		t.Errorf("HeadObject calls = %d, PutObject calls = %d, CopyObject calls = %d, want 2, 1 and 1", fake.heads, fake.puts, fake.copies)
+	}
+
+	// 같은 실행에서 같은 key 를 다시 쓰려는 다른 기사가 감지되어야 함
+	if _, err := uploader.Upload(ctx, key, []byte("# 다른 기사"), map[string]string{"run-id": "run2"}); !errors.Is(err, ErrWrittenByRun) {
+		t.Errorf("Upload of another article in run2 = %v, want ErrWrittenByRun", err)
+	}
+	// 같은 기사의 재시도는 성공으로 처리
+	skipped, err = uploader.Upload(ctx, key, content, map[string]string{"run-id": "run2"})
+	if err != nil || !skipped {
+		t.Errorf("retried Upload in run2 = (%v, %v), want (true, nil)", skipped, err)
+	}
+	if got := string(fake.object("news", key).body); got != string(content) {
+		t.Errorf("content after metadata update = %q, want %q", got, content)
+	}
+}
+
+func TestUploadDoesNotOverwriteRacingWrite(t *testing.T) {
+	fake, client := newFakeS3(t)
+	uploader := S3Uploader{Client: client, BucketName: "news"}
+	key := "news/2025-01-02/politics_0.md"
+
+	// 첫 HEAD 와 PUT 사이에 같은 실행의 다른 기사가 먼저 쓰임
+	fake.afterHead = func(path string) {
+		fake.afterHead = nil
+		fake.objects[path] = fake.newObject([]byte("# 먼저 쓴 기사"), map[string]string{"run-id": "run1", "contenthash": "other"}, http.Header{})
+	}
+	_, err := uploader.Upload(context.Background(), key, []byte("# 제목"), map[string]string{"run-id": "run1"})
+	if !errors.Is(err, ErrWrittenByRun) {
+		t.Errorf("Upload racing another write = %v, want ErrWrittenByRun", err)
+	}
+	if got := string(fake.object("news", key).body); got != "# 먼저 쓴 기사" {
+		t.Errorf("%s = %q, want the racing write kept", key, got)
+	}
+	if fake.heads != 2 || fake.puts != 0 {
+		t.Errorf("HeadObject calls = %d, PutObject calls = %d, want 2 and 0", fake.heads, fake.puts)
+	}
+}
+
+func TestUploadChangedContent(t *testing.T) {
+	fake, client := newFakeS3(t)
+	uploader := S3Uploader{Client: client, BucketName: "news"}
+	ctx := context.Background()
+	key := "news/2025-01-02/it_0.md"
+
+	if _, err := uploader.Upload(ctx, key, []byte("old"), nil); err != nil {
+		t.Fatal(err)
+	}
+	skipped, err := uploader.Upload(ctx, key, []byte("new"), nil)
+	if err != nil || skipped {
+		t.Fatalf("Upload of changed content = (%v, %v), want (false, nil)", skipped, err)
+	}
+	if got := string(fake.object("news", key).body); got != "new" {
+		t.Errorf("stored content = %q, want %q", got, "new")
+	}
+}
+
+func TestObjectKey(t *testing.T) {
+	date := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"politics_0", "news/2025-01-02/politics_0.md"},
+		{"politics_1", "news/2025-01-02/politics_1.md"},
+		{"../etc/passwd", "news/2025-01-02/..-etc-passwd.md"},
+		{"it 0\n", "news/2025-01-02/it-0-.md"},
+	}
+	for _, tt := range tests {
+		if got := ObjectKey(date, tt.name); got != tt.want {
+			t.Errorf("ObjectKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if ObjectKey(date, "politics_0") == ObjectKey(date, "politics_1") {
+		t.Error("two indices of a category map to the same key")
+	}
+}
+
+func TestLambdaHandlerStoresArticleMetadata(t *testing.T) {
+	fake, _ := newFakeS3(t)
+	t.Setenv("AWS_ENDPOINT_URL_S3", fake.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("S3_BUCKET_NAME", "news")
+
+	response, err := LambdaHandler(context.Background(), events.APIGatewayProxyRequest{
+		Body: "# 제목",
+		Headers: map[string]string{
+			"x-category-sniij": "politics_0",
+			"x-source-sniij":   "https://n.news.naver.com/article/001/0001",
+			"x-run-id-sniij":   "run1",
+		},
+	})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("LambdaHandler = (%d %s, %v), want 200", response.StatusCode, response.Body, err)
+	}
+
+	object := fake.object("news", ObjectKey(time.Now(), "politics_0"))
+	if object == nil {
+		t.Fatalf("no object written, have %v", fake.objects)
+	}
+	want := map[string]string{
+		"category": "politics_0",
+		"source":   "https://n.news.naver.com/article/001/0001",
+		"run-id":   "run1",
+	}
+	for name, value := range want {
+		if got := object.metadata[name]; got != value {
+			t.Errorf("metadata %s = %q, want %q", name, got, value)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, object.metadata["uploaded-at"]); err != nil {
+		t.Errorf("metadata uploaded-at = %q, want an RFC3339 time", object.metadata["uploaded-at"])
+	}
+}
+
+func TestLambdaHandlerReplicaRequired(t *testing.T) {
+	fake, _ := newFakeS3(t)
+	t.Setenv("AWS_ENDPOINT_URL_S3", fake.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_MAX_ATTEMPTS", "1")
+	t.Setenv("S3_BUCKET_NAME", "news")
+	t.Setenv("REPLICA_BUCKET_NAME", "news-replica")
+	t.Setenv("REPLICA_MODE", "required")
+	request := events.APIGatewayProxyRequest{
+		Body:    "# 제목",
+		Headers: map[string]string{"x-category-sniij": "politics_0"},
+	}
+	key := ObjectKey(time.Now(), "politics_0")
+
+	response, err := LambdaHandler(context.Background(), request)
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("LambdaHandler = (%d %s, %v), want 200", response.StatusCode, response.Body, err)
+	}
+	for _, bucket := range []string{"news", "news-replica"} {
+		object := fake.object(bucket, key)
+		if object == nil || string(object.body) != "# 제목" {
+			t.Errorf("%s/%s = %v, want the uploaded article", bucket, key, object)
+		}
+	}
+
+	// 복제 실패는 required 모드에서만 업로드 실패
+	fake.failBucket = "news-replica"
+	request.Body = "# 수정된 제목"
+	response, err = LambdaHandler(context.Background(), request)
+	if err != nil || response.StatusCode != 500 || !strings.Contains(response.Body, "Failed to replicate file") {
+		t.Errorf("LambdaHandler with a failing replica = (%d %s, %v), want 500", response.StatusCode, response.Body, err)
+	}
+	// SDK 오류 메시지의 따옴표가 들어가도 본문은 JSON
+	if !json.Valid([]byte(response.Body)) {
+		t.Errorf("error body %s is not JSON", response.Body)
+	}
+	t.Setenv("REPLICA_MODE", "")
+	response, err = LambdaHandler(context.Background(), request)
+	if err != nil || response.StatusCode != 200 {
+		t.Errorf("best effort LambdaHandler with a failing replica = (%d %s, %v), want 200", response.StatusCode, response.Body, err)
+	}
+}
+
+func TestLambdaHandlerRetryAfterFailedReplicaSucceeds(t *testing.T) {
+	fake, _ := newFakeS3(t)
+	t.Setenv("AWS_ENDPOINT_URL_S3", fake.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_MAX_ATTEMPTS", "1")
+	t.Setenv("S3_BUCKET_NAME", "news")
+	t.Setenv("REPLICA_BUCKET_NAME", "news-replica")
+	t.Setenv("REPLICA_MODE", "required")
+	request := events.APIGatewayProxyRequest{
+		Body:    "# 제목",
+		Headers: map[string]string{"x-category-sniij": "politics_0", "x-run-id-sniij": "run1"},
+	}
+	key := ObjectKey(time.Now(), "politics_0")
+
+	// 본 버킷에는 써졌지만 복제 실패로 500 을 받은 요청을 auto-push 가 재시도
+	fake.failBucket = "news-replica"
+	if response, _ := LambdaHandler(context.Background(), request); response.StatusCode != 500 {
+		t.Fatalf("LambdaHandler with a failing replica = %d %s, want 500", response.StatusCode, response.Body)
+	}
+	fake.failBucket = ""
+	response, err := LambdaHandler(context.Background(), request)
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("retried LambdaHandler = (%d %s, %v), want 200", response.StatusCode, response.Body, err)
+	}
+	for _, bucket := range []string{"news", "news-replica"} {
+		if object := fake.object(bucket, key); object == nil || string(object.body) != "# 제목" {
+			t.Errorf("%s/%s = %v, want the uploaded article", bucket, key, object)
+		}
+	}
+}
+
+func TestUploadRequestsServerSideEncryption(t *testing.T) {
+	tests := []struct {
+		name      string
+		sse       string
+		kmsKeyID  string
+		wantSSE   string
+		wantKeyID string
+	}{
+		{"disabled", "", "", "", ""},
+		{"kms key without S3_SSE", "", "alias/news", "", ""},
+		{"AES256", "true", "", "AES256", ""},
+		{"KMS", "true", "alias/news", "aws:kms", "alias/news"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("S3_SSE", tt.sse)
+			t.Setenv("S3_SSE_KMS_KEY_ID", tt.kmsKeyID)
+			fake, client := newFakeS3(t)
+			uploader := S3Uploader{Client: client, BucketName: "news"}
+			key := "news/2025-01-02/politics_0.md"
+
+			// 새 업로드(PutObject)와 메타데이터만 바꾸는 복사(CopyObject) 모두 확인
+			for _, runID := range []string{"run1", "run2"} {
+				if _, err := uploader.Upload(context.Background(), key, []byte("# 제목"), map[string]string{"run-id": runID}); err != nil {
+					t.Fatal(err)
+				}
+				header := fake.object("news", key).header
+				if got := header.Get("x-amz-server-side-encryption"); got != tt.wantSSE {
+					t.Errorf("%s: server-side encryption = %q, want %q", runID, got, tt.wantSSE)
+				}
+				if got := header.Get("x-amz-server-side-encryption-aws-kms-key-id"); got != tt.wantKeyID {
+					t.Errorf("%s: KMS key ID = %q, want %q", runID, got, tt.wantKeyID)
+				}
+			}
+			if fake.puts != 1 || fake.copies != 1 {
+				t.Errorf("PutObject calls = %d, CopyObject calls = %d, want 1 and 1", fake.puts, fake.copies)
+			}
+		})
+	}
+}
+
+func TestLambdaHandlerBodiesAreJSON(t *testing.T) {
+	fake, _ := newFakeS3(t)
+	t.Setenv("AWS_ENDPOINT_URL_S3", fake.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("S3_BUCKET_NAME", "news")
+	t.Setenv("REPLICA_BUCKET_NAME", "")
+	t.Setenv("PRESIGN_TTL", "")
+	request := events.APIGatewayProxyRequest{
+		Body:    "# 제목",
+		Headers: map[string]string{"x-category-sniij": "politics_0", "x-run-id-sniij": "run1"},
+	}
+	decode := func(response events.APIGatewayProxyResponse) map[string]interface{} {
+		t.Helper()
+		var body map[string]interface{}
+		if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+			t.Fatalf("body %q is not JSON: %v", response.Body, err)
+		}
+		return body
+	}
+
+	response, err := LambdaHandler(context.Background(), request)
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("LambdaHandler = (%d %s, %v), want 200", response.StatusCode, response.Body, err)
+	}
+	body := decode(response)
+	if body["filename"] != ObjectKey(time.Now(), "politics_0") || body["skipped"] != false {
+		t.Errorf("success body = %v, want the filename and skipped false", body)
+	}
+	if url, _ := body["url"].(string); !strings.HasPrefix(url, fake.URL+"/news/") || !strings.Contains(url, "X-Amz-Signature=") {
+		t.Errorf("presigned url = %q, want a signed URL of the object", url)
+	}
+
+	request.Body = "# 다른 기사"
+	response, _ = LambdaHandler(context.Background(), request)
+	if body := decode(response); response.StatusCode != 409 || body["filename"] != ObjectKey(time.Now(), "politics_0") {
+		t.Errorf("duplicate key response = %d %v, want 409 with the filename", response.StatusCode, body)
+	}
+
+	t.Setenv("PRESIGN_TTL", "0")
+	request.Body = "# 제목"
+	request.Headers["x-run-id-sniij"] = "run2"
+	response, _ = LambdaHandler(context.Background(), request)
+	if body := decode(response); response.StatusCode != 200 || body["skipped"] != true || body["url"] != nil {
+		t.Errorf("response without presigning = %d %v, want 200, skipped and no url", response.StatusCode, body)
+	}
+
+	// 따옴표가 들어간 날짜도 JSON 을 깨뜨리지 않음
+	date := `2025-01-02"}`
+	response, _ = LambdaHandler(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"mode": "archive", "date": date},
+	})
+	if body := decode(response); response.StatusCode != 400 || body["error"] != "Invalid date: "+date {
+		t.Errorf("archive response = %d %v, want 400 with the date", response.StatusCode, body)
+	}
+}
+
+func TestLambdaHandlerKeepsKeysUniqueWithinRun(t *testing.T) {
+	fake, _ := newFakeS3(t)
+	t.Setenv("AWS_ENDPOINT_URL_S3", fake.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("S3_BUCKET_NAME", "news")
+	t.Setenv("REPLICA_BUCKET_NAME", "")
+	upload := func(category, runID, content string) events.APIGatewayProxyResponse {
+		t.Helper()
+		response, err := LambdaHandler(context.Background(), events.APIGatewayProxyRequest{
+			Body:    content,
+			Headers: map[string]string{"x-category-sniij": category, "x-run-id-sniij": runID},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+	key := ObjectKey(time.Now(), "politics_0")
+
+	if response := upload("politics_0", "run1", "# 첫 기사"); response.StatusCode != 200 {
+		t.Fatalf("first upload = %d %s, want 200", response.StatusCode, response.Body)
+	}
+	if response := upload("politics_1", "run1", "# 다음 기사"); response.StatusCode != 200 {
+		t.Errorf("upload of the next index = %d %s, want 200", response.StatusCode, response.Body)
+	}
+	// 같은 실행에서 같은 key 를 쓰는 다른 기사는 거부되고 앞 기사를 덮어쓰지 않음
+	if response := upload("politics_0", "run1", "# 다른 기사"); response.StatusCode != 409 {
+		t.Errorf("second write of %s in run1 = %d %s, want 409", key, response.StatusCode, response.Body)
+	}
+	if got := string(fake.object("news", key).body); got != "# 첫 기사" {
+		t.Errorf("%s = %q, want the first article kept", key, got)
+	}
+
+	// 다음 실행은 같은 key 를 다시 쓸 수 있음
+	if response := upload("politics_0", "run2", "# 재실행 기사"); response.StatusCode != 200 {
+		t.Errorf("write of %s in run2 = %d %s, want 200", key, response.StatusCode, response.Body)
+	}
+	if got := string(fake.object("news", key).body); got != "# 재실행 기사" {
+		t.Errorf("%s = %q, want the article of run2", key, got)
+	}
+
+}